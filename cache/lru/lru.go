@@ -1,17 +1,21 @@
 // Package lru provides a basic least-recently-used cache. It can be used as
 // either a read-through or a manual cache.
 //
-// It supports per-entry cost, and custom on-retrieve and on-evict callbacks.
+// It supports per-entry cost and time-to-live, and custom on-retrieve and
+// on-evict callbacks.
+//
+// TypedCache holds keys and values of the given types. Cache is the original,
+// untyped version, holding keys and values of any type, for existing callers.
 //
 // Anticipated usage (read-through):
 //
-//	func retrieveEntry(key lru.Key) (interface{}, lru.Cost, error) {
+//	func retrieveEntry(key string) (*Entry, lru.Cost, error) {
 //		// Expensive retrieval operation.
 //	}
-//	func evictEntry(key lru.Key, value interface{}) {
+//	func evictEntry(key string, value *Entry) {
 //		// Optional release operation.
 //	}
-//	cache := lru.New(5)
+//	cache := lru.NewTyped[string, *Entry](5)
 //	cache.OnRetrieve = retrieveEntry
 //	cache.OnEvict = evictEntry
 //	value, err := cache.Get(key)
@@ -19,7 +23,7 @@
 //
 // Anticipated usage (manual caching):
 //
-//	cache := lru.New(5)
+//	cache := lru.NewTyped[string, *Entry](5)
 //	cache.Put(key1, value1)
 //	cache.Put(key2, value2)
 //	value, err := cache.Get(key1)
@@ -33,6 +37,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"time"
 )
 
 // ErrMissingEntry is returned from `Get` if there is no such entry in the
@@ -51,27 +56,41 @@ type Key interface{}
 type Cost int64
 
 // cell is the type actually stored in each list entry.
-type cell struct {
-	key   Key
-	value interface{}
-	cost  Cost
+type cell[K comparable, V any] struct {
+	key     K
+	value   V
+	cost    Cost
+	expires time.Time // Zero if the entry does not expire.
 }
 
-// RetrieverFunc is called when the cache is missing a necessary value.
+// TypedRetrieverFunc is called when the cache is missing a necessary value.
 //
 // If it returns an error, the value is not added to the cache, and the error
 // is returned from `Get`.
-type RetrieverFunc func(key Key) (value interface{}, cost Cost, err error)
+type TypedRetrieverFunc[K comparable, V any] func(key K) (value V, cost Cost, err error)
+
+// TypedEvictionFunc is called when the cache evicts a value.
+type TypedEvictionFunc[K comparable, V any] func(key K, value V)
 
-// EvictionFunc is called when the cache evicts a value.
-type EvictionFunc func(key Key, value interface{})
+// RetrieverFunc is the TypedRetrieverFunc of the untyped Cache.
+type RetrieverFunc = TypedRetrieverFunc[Key, interface{}]
+
+// EvictionFunc is the TypedEvictionFunc of the untyped Cache.
+type EvictionFunc = TypedEvictionFunc[Key, interface{}]
+
+// Cache is the untyped cache, which holds keys and values of any type.
+//
+// Kept for existing callers. New code should use TypedCache, to avoid type
+// assertions.
+type Cache = TypedCache[Key, interface{}]
 
-// Cache is the main cache type.
+// TypedCache is the main cache type, holding values of type V by keys of type
+// K.
 //
 // Not internally synchronized.
-type Cache struct {
-	list    *list.List            // Entries are `*cell`s.
-	entries map[Key]*list.Element // Same entries as in the list.
+type TypedCache[K comparable, V any] struct {
+	list    *list.List          // Entries are `*cell[K, V]`s.
+	entries map[K]*list.Element // Same entries as in the list.
 	cost    Cost
 
 	// MaxCost is the cost of entries allowed in the cache.
@@ -86,14 +105,35 @@ type Cache struct {
 	// OnRetrieve, if not nil, is called when Get does not find an entry in the
 	// cache.
 	//
-	// If nil, the return value will be nil with a `ErrMissingEntry` error.
-	OnRetrieve RetrieverFunc
+	// If nil, the return value will be the zero value with a `ErrMissingEntry`
+	// error.
+	OnRetrieve TypedRetrieverFunc[K, V]
 
 	// OnEvict, if not nil, is called each time a cache entry is evicted.
-	OnEvict EvictionFunc
+	//
+	// Expired entries are evicted too, when found by Get or Reap.
+	OnEvict TypedEvictionFunc[K, V]
+
+	// TTL, if positive, is how long entries added by Put, or retrieved by Get,
+	// stay in the cache. Use PutTTL for a different time for one entry.
+	//
+	// Expired entries are treated as missing by Get, and evicted when Get finds
+	// them, or by Reap. Until then, they count towards the cost of the cache.
+	TTL time.Duration
+
+	// Now, if not nil, returns the current time, for expiring entries. Defaults
+	// to time.Now. Mostly useful for tests.
+	Now func() time.Time
 }
 
-// New returns a new LRU cache with the given maximum size.
+// New returns a new untyped LRU cache with the given maximum size.
+//
+// See NewTyped.
+func New(maxCost Cost) *Cache {
+	return NewTyped[Key, interface{}](maxCost)
+}
+
+// NewTyped returns a new LRU cache with the given maximum size.
 //
 // You may want to add a retriever and/or eviction function to the returned
 // cache.
@@ -108,16 +148,16 @@ type Cache struct {
 // Negative costs are not supported and will cause panics.
 //
 // Maximum cache cost is `math.MaxInt64`.
-func New(maxCost Cost) *Cache {
-	return &Cache{
+func NewTyped[K comparable, V any](maxCost Cost) *TypedCache[K, V] {
+	return &TypedCache[K, V]{
 		list:    list.New(),
-		entries: make(map[Key]*list.Element),
+		entries: make(map[K]*list.Element),
 		MaxCost: maxCost,
 	}
 }
 
 // Cost returns the current cost of the entries in the cache.
-func (c *Cache) Cost() Cost { return c.cost }
+func (c *TypedCache[K, V]) Cost() Cost { return c.cost }
 
 // Get retrieves an entry.
 //
@@ -132,15 +172,17 @@ func (c *Cache) Cost() Cost { return c.cost }
 //
 // If the retriever returns an error, the value will not be saved in the cache,
 // but this will return whatever value the retriever returned.
-func (c *Cache) Get(key Key) (value interface{}, err error) {
+func (c *TypedCache[K, V]) Get(key K) (value V, err error) {
 	entry, ok := c.entries[key]
-	if ok {
+	if ok && c.expired(entry.Value.(*cell[K, V])) {
+		c.remove(entry)
+	} else if ok {
 		c.list.MoveToBack(entry)
-		return entry.Value.(*cell).value, nil
+		return entry.Value.(*cell[K, V]).value, nil
 	}
 
 	if c.OnRetrieve == nil {
-		return nil, ErrMissingEntry
+		return value, ErrMissingEntry
 	}
 
 	var cost Cost
@@ -148,7 +190,7 @@ func (c *Cache) Get(key Key) (value interface{}, err error) {
 	if err != nil {
 		return
 	}
-	c.Put(key, cost, value)
+	c.PutTTL(key, cost, value, c.TTL)
 	return
 }
 
@@ -161,16 +203,24 @@ func (c *Cache) Get(key Key) (value interface{}, err error) {
 //
 // Panics if the cost of the new entry would overflow the cache cost.
 //
-// Returns the previous value of the entry, or nil.
-func (c *Cache) Put(key Key, cost Cost, value interface{}) interface{} {
+// The entry expires after TTL, if it is set.
+//
+// Returns the previous value of the entry, or the zero value.
+func (c *TypedCache[K, V]) Put(key K, cost Cost, value V) V {
+	return c.PutTTL(key, cost, value, c.TTL)
+}
+
+// PutTTL is like Put, but the entry expires after `ttl` instead of TTL. If
+// `ttl` is 0 or less, the entry does not expire.
+func (c *TypedCache[K, V]) PutTTL(key K, cost Cost, value V, ttl time.Duration) V {
 	if cost < 0 {
 		panic(fmt.Errorf("illegal cost: entry %v cost %d is negative", key, cost))
 	}
 
-	var prev interface{}
+	var prev V
 	entry, ok := c.entries[key]
 	if ok {
-		entryCell := entry.Value.(*cell)
+		entryCell := entry.Value.(*cell[K, V])
 
 		if c.cost-entryCell.cost+cost < 0 {
 			panic(fmt.Errorf("cost overflow: cache cost %d + entry %v cost %d > limit %d", c.cost-entryCell.cost, key, cost, math.MaxInt64))
@@ -183,11 +233,12 @@ func (c *Cache) Put(key Key, cost Cost, value interface{}) interface{} {
 		prev = entryCell.value
 		entryCell.cost = cost
 		entryCell.value = value
+		entryCell.expires = c.expiry(ttl)
 	} else {
 		if c.cost+cost < 0 {
 			panic(fmt.Errorf("cost overflow: cache cost %d + entry %v cost %d > limit %d", c.cost, key, cost, math.MaxInt64))
 		}
-		c.entries[key] = c.list.PushBack(&cell{key, value, cost})
+		c.entries[key] = c.list.PushBack(&cell[K, V]{key, value, cost, c.expiry(ttl)})
 		c.cost += cost
 	}
 	for c.cost > c.MaxCost && len(c.entries) > 1 {
@@ -199,7 +250,7 @@ func (c *Cache) Put(key Key, cost Cost, value interface{}) interface{} {
 // Clear evicts every entry in the cache.
 //
 // If there is an OnEvict function, calls it for each entry.
-func (c *Cache) Clear() {
+func (c *TypedCache[K, V]) Clear() {
 	for len(c.entries) > 0 {
 		c.EvictOldest()
 	}
@@ -207,19 +258,14 @@ func (c *Cache) Clear() {
 
 // EvictOldest evicts the least recently used entry from the cache.
 //
-// Returns the value evicted, or nil if the cache was empty.
-func (c *Cache) EvictOldest() interface{} {
+// Returns the value evicted, or the zero value if the cache was empty.
+func (c *TypedCache[K, V]) EvictOldest() V {
 	if len(c.entries) == 0 {
-		return nil
+		var zero V
+		return zero
 	}
 
-	value := c.list.Remove(c.list.Front()).(*cell)
-	delete(c.entries, value.key)
-	c.cost -= value.cost
-	if c.OnEvict != nil {
-		c.OnEvict(value.key, value.value)
-	}
-	return value.value
+	return c.remove(c.list.Front())
 }
 
 // Evict evicts a specific entry from the cache.
@@ -228,18 +274,65 @@ func (c *Cache) EvictOldest() interface{} {
 //
 // Calls the OnEvict function if there is one.
 //
-// Returns the value evicted, or nil.
-func (c *Cache) Evict(key Key) interface{} {
+// Returns the value evicted, or the zero value.
+func (c *TypedCache[K, V]) Evict(key K) V {
 	entry, ok := c.entries[key]
 	if !ok {
-		return nil
+		var zero V
+		return zero
 	}
 
-	value := entry.Value.(*cell)
+	return c.remove(entry)
+}
+
+// Reap evicts every expired entry from the cache, calling the OnEvict function
+// for each one if there is one.
+//
+// Returns the number of entries evicted.
+func (c *TypedCache[K, V]) Reap() int {
+	n := 0
+	for entry := c.list.Front(); entry != nil; {
+		next := entry.Next()
+		if c.expired(entry.Value.(*cell[K, V])) {
+			c.remove(entry)
+			n++
+		}
+		entry = next
+	}
+	return n
+}
+
+// remove evicts an entry, calling the OnEvict function if there is one.
+//
+// Returns the value evicted.
+func (c *TypedCache[K, V]) remove(entry *list.Element) V {
+	value := c.list.Remove(entry).(*cell[K, V])
 	delete(c.entries, value.key)
-	c.list.Remove(entry)
+	c.cost -= value.cost
 	if c.OnEvict != nil {
 		c.OnEvict(value.key, value.value)
 	}
 	return value.value
 }
+
+// now returns the current time, by the Now function if there is one.
+func (c *TypedCache[K, V]) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// expiry returns the time an entry added now with `ttl` expires, or the zero
+// time if it does not.
+func (c *TypedCache[K, V]) expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return c.now().Add(ttl)
+}
+
+// expired returns true if the entry has expired.
+func (c *TypedCache[K, V]) expired(entry *cell[K, V]) bool {
+	return !entry.expires.IsZero() && !c.now().Before(entry.expires)
+}
@@ -3,7 +3,9 @@ package lru
 import (
 	"fmt"
 	"math"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestGetCachedEntry(t *testing.T) {
@@ -1072,3 +1074,179 @@ func TestPutUpdatesCostAndValue(t *testing.T) {
 		t.Fatalf("got %v want %v", got, want)
 	}
 }
+
+func TestEvictUpdatesCost(t *testing.T) {
+	// Verify evicting a specific entry removes its cost.
+	c := New(10)
+	c.Put(1, 4, "one")
+	c.Put(2, 5, "two")
+	c.Evict(1)
+	if got, want := c.Cost(), Cost(5); got != want {
+		t.Errorf("got %v want %v cost", got, want)
+	}
+
+	// Room for another entry of cost 5 without evicting 2.
+	c.Put(3, 5, "three")
+	if _, err := c.Get(2); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestTypedCache(t *testing.T) {
+	// Verify a typed cache needs no type assertions.
+	type entry struct{ n int }
+	calls := 0
+	c := NewTyped[string, *entry](2)
+	c.OnRetrieve = func(key string) (*entry, Cost, error) {
+		calls++
+		if key == "bad" {
+			return nil, 0, fmt.Errorf("bad key %v", key)
+		}
+		return &entry{len(key)}, 1, nil
+	}
+	var evicted []string
+	c.OnEvict = func(key string, value *entry) {
+		evicted = append(evicted, fmt.Sprint(key, "=", value.n))
+	}
+
+	for _, key := range []string{"a", "bb", "a", "ccc"} {
+		v, err := c.Get(key)
+		if err != nil {
+			t.Errorf("unexpected error %v", err)
+		} else if got, want := v.n, len(key); got != want {
+			t.Errorf("got %v want %v", got, want)
+		}
+	}
+	if got, want := calls, 3; got != want {
+		t.Errorf("got %v want %v calls", got, want)
+	}
+	if got, want := fmt.Sprint(evicted), "[bb=2]"; got != want {
+		t.Errorf("got %v want %v evicted", got, want)
+	}
+	if _, err := c.Get("bad"); err == nil {
+		t.Errorf("expected error")
+	}
+
+	if got := c.Put("a", 1, &entry{10}); got == nil || got.n != 1 {
+		t.Errorf("got %v want previous entry 1", got)
+	}
+	if got := c.Evict("missing"); got != nil {
+		t.Errorf("got %v want nil from Evict", got)
+	}
+
+	c.OnRetrieve = nil
+	if v, err := c.Get("missing"); v != nil || err != ErrMissingEntry {
+		t.Errorf("got %v, %v want nil, %v", v, err, ErrMissingEntry)
+	}
+}
+
+func TestUntypedFuncTypes(t *testing.T) {
+	// Verify the untyped function types still work with the untyped cache.
+	var retrieve RetrieverFunc = func(key Key) (interface{}, Cost, error) {
+		return key, 1, nil
+	}
+	var evict EvictionFunc = func(key Key, value interface{}) {}
+	c := New(1)
+	c.OnRetrieve = retrieve
+	c.OnEvict = evict
+	if v, err := c.Get(1); err != nil || v != 1 {
+		t.Errorf("got %v, %v want 1, nil", v, err)
+	}
+}
+
+// fakeClock is a clock for testing expiry, which only moves when told.
+type fakeClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func newFakeClock() *fakeClock { return &fakeClock{now: time.Unix(1000, 0)} }
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestTTLExpiresEntries(t *testing.T) {
+	// Verify expired entries are misses, retrieved again, and evicted.
+	clock := newFakeClock()
+	calls := 0
+	var evicted []string
+	c := NewTyped[string, int](10)
+	c.TTL = time.Minute
+	c.Now = clock.Now
+	c.OnRetrieve = func(key string) (int, Cost, error) {
+		calls++
+		return calls, 1, nil
+	}
+	c.OnEvict = func(key string, value int) {
+		evicted = append(evicted, fmt.Sprint(key, "=", value))
+	}
+
+	if v, err := c.Get("a"); err != nil || v != 1 {
+		t.Errorf("got %v, %v want 1, nil", v, err)
+	}
+	clock.Advance(59 * time.Second)
+	if v, err := c.Get("a"); err != nil || v != 1 {
+		t.Errorf("got %v, %v want 1, nil before expiry", v, err)
+	}
+	clock.Advance(time.Second)
+	if v, err := c.Get("a"); err != nil || v != 2 {
+		t.Errorf("got %v, %v want 2, nil after expiry", v, err)
+	}
+	if got, want := fmt.Sprint(evicted), "[a=1]"; got != want {
+		t.Errorf("got %v want %v evicted", got, want)
+	}
+	if got, want := c.Cost(), Cost(1); got != want {
+		t.Errorf("got %v want %v cost", got, want)
+	}
+
+	// Without a retriever, an expired entry is missing.
+	c.OnRetrieve = nil
+	clock.Advance(time.Minute)
+	if _, err := c.Get("a"); err != ErrMissingEntry {
+		t.Errorf("got %v want %v after expiry", err, ErrMissingEntry)
+	}
+}
+
+func TestPutTTL(t *testing.T) {
+	// Verify per-entry TTLs override the default, and Reap evicts expired
+	// entries.
+	clock := newFakeClock()
+	var evicted []string
+	c := NewTyped[string, int](10)
+	c.TTL = time.Hour
+	c.Now = clock.Now
+	c.OnEvict = func(key string, value int) { evicted = append(evicted, key) }
+
+	c.Put("default", 1, 1)
+	c.PutTTL("short", 1, 2, time.Second)
+	c.PutTTL("forever", 1, 3, 0)
+	c.PutTTL("refreshed", 1, 4, time.Second)
+	c.Put("refreshed", 1, 5)
+
+	clock.Advance(time.Second)
+	if got, want := c.Reap(), 1; got != want {
+		t.Errorf("got %v want %v reaped", got, want)
+	}
+	clock.Advance(time.Hour)
+	if got, want := c.Reap(), 2; got != want {
+		t.Errorf("got %v want %v reaped", got, want)
+	}
+	if got, want := fmt.Sprint(evicted), "[short default refreshed]"; got != want {
+		t.Errorf("got %v want %v evicted", got, want)
+	}
+	if v, err := c.Get("forever"); err != nil || v != 3 {
+		t.Errorf("got %v, %v want 3, nil", v, err)
+	}
+	if got, want := c.Cost(), Cost(1); got != want {
+		t.Errorf("got %v want %v cost", got, want)
+	}
+}
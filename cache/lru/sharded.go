@@ -0,0 +1,298 @@
+package lru
+
+import (
+	"hash/maphash"
+	"math"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultShards is the number of shards in a ShardedCache, if not given.
+const DefaultShards = 16
+
+// ShardedCache is a cache made of independent SyncCaches, called shards, with
+// each key belonging to one of them by its hash. Calls for keys in different
+// shards do not contend for a lock, so it holds up better than a SyncCache
+// under heavy concurrent use.
+//
+// Each shard holds an equal share of the maximum cost, and evicts its own least
+// recently used entries, so entries are not evicted in strict LRU order across
+// the whole cache.
+//
+// Safe for concurrent use.
+type ShardedCache[K comparable, V any] struct {
+	shards  []*SyncCache[K, V]
+	maxCost atomic.Int64
+	seed    maphash.Seed
+
+	// Hash, if not nil, returns the hash of a key, which picks its shard.
+	//
+	// If nil, strings and integers are hashed directly, and other keys with
+	// reflection, which is slower. Set it for other key types on hot paths.
+	//
+	// Keys that are equal must have the same hash.
+	//
+	// Must be set before the cache is used.
+	Hash func(key K) uint64
+
+	// OnRetrieve, if not nil, is called when Get does not find an entry in the
+	// cache, like SyncCache.OnRetrieve.
+	//
+	// Must be set before the cache is used.
+	OnRetrieve TypedRetrieverFunc[K, V]
+
+	// OnEvict, if not nil, is called each time a cache entry is evicted.
+	//
+	// Called with the entry's shard locked, so it must not use the cache.
+	//
+	// Must be set before the cache is used.
+	OnEvict TypedEvictionFunc[K, V]
+
+	// TTL, if positive, is how long entries stay in the cache, like
+	// TypedCache.TTL.
+	//
+	// Must be set before the cache is used.
+	TTL time.Duration
+
+	// Now, if not nil, returns the current time, for expiring entries, like
+	// TypedCache.Now.
+	//
+	// Must be set before the cache is used.
+	Now func() time.Time
+}
+
+// NewSharded returns a new sharded LRU cache with the given maximum size,
+// split evenly across `shards` shards. If `shards` is 0 or less, uses
+// DefaultShards.
+//
+// See NewTyped for the meaning of the cost. Each shard allows at least a cost
+// of 1, so a small maximum with many shards is rounded up.
+func NewSharded[K comparable, V any](shards int, maxCost Cost) *ShardedCache[K, V] {
+	if shards <= 0 {
+		shards = DefaultShards
+	}
+	c := &ShardedCache[K, V]{
+		shards: make([]*SyncCache[K, V], shards),
+		seed:   maphash.MakeSeed(),
+	}
+	for i := range c.shards {
+		s := NewSync[K, V](0)
+		s.OnRetrieve = func(key K) (value V, cost Cost, err error) {
+			if c.OnRetrieve == nil {
+				return value, 0, ErrMissingEntry
+			}
+			return c.OnRetrieve(key)
+		}
+		s.OnEvict = func(key K, value V) {
+			if c.OnEvict != nil {
+				c.OnEvict(key, value)
+			}
+		}
+		s.Now = func() time.Time {
+			if c.Now != nil {
+				return c.Now()
+			}
+			return time.Now()
+		}
+		c.shards[i] = s
+	}
+	c.SetMaxCost(maxCost)
+	return c
+}
+
+// shard returns the shard for `key`.
+func (c *ShardedCache[K, V]) shard(key K) *SyncCache[K, V] {
+	var h uint64
+	if c.Hash != nil {
+		h = c.Hash(key)
+	} else {
+		h = c.hash(key)
+	}
+	return c.shards[h%uint64(len(c.shards))]
+}
+
+// hash is the default Hash.
+func (c *ShardedCache[K, V]) hash(key K) uint64 {
+	var n uint64
+	switch k := any(key).(type) {
+	case string:
+		return maphash.String(c.seed, k)
+	case int:
+		n = uint64(k)
+	case int8:
+		n = uint64(k)
+	case int16:
+		n = uint64(k)
+	case int32:
+		n = uint64(k)
+	case int64:
+		n = uint64(k)
+	case uint:
+		n = uint64(k)
+	case uint8:
+		n = uint64(k)
+	case uint16:
+		n = uint64(k)
+	case uint32:
+		n = uint64(k)
+	case uint64:
+		n = k
+	case uintptr:
+		n = uint64(k)
+	default:
+		var h maphash.Hash
+		h.SetSeed(c.seed)
+		hashValue(&h, reflect.ValueOf(key))
+		return h.Sum64()
+	}
+	// Fibonacci hashing spreads sequential keys across the shards.
+	return (n * 0x9e3779b97f4a7c15) >> 32
+}
+
+// hashValue writes `v` to `h`, so that values that are equal by == write the
+// same bytes. Pointers and channels are hashed by address, not by what they
+// point to, and -0 like 0.
+func hashValue(h *maphash.Hash, v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			hashUint(h, 1)
+		} else {
+			hashUint(h, 0)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		hashUint(h, uint64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		hashUint(h, v.Uint())
+	case reflect.Float32, reflect.Float64:
+		hashFloat(h, v.Float())
+	case reflect.Complex64, reflect.Complex128:
+		hashFloat(h, real(v.Complex()))
+		hashFloat(h, imag(v.Complex()))
+	case reflect.String:
+		hashUint(h, uint64(v.Len()))
+		h.WriteString(v.String())
+	case reflect.Pointer, reflect.Chan, reflect.UnsafePointer:
+		hashUint(h, uint64(v.Pointer()))
+	case reflect.Interface:
+		if !v.IsNil() {
+			hashValue(h, v.Elem())
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			hashValue(h, v.Index(i))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if t.Field(i).Name != "_" { // Ignored by ==.
+				hashValue(h, v.Field(i))
+			}
+		}
+	}
+	// Other kinds are not comparable, so cannot be keys. A nil interface key
+	// gives an invalid value, and writes nothing.
+}
+
+// hashUint writes `n` to `h`.
+func hashUint(h *maphash.Hash, n uint64) {
+	var b [8]byte
+	for i := range b {
+		b[i] = byte(n >> (8 * i))
+	}
+	h.Write(b[:])
+}
+
+// hashFloat writes `f` to `h`, with -0 written as 0, as they are equal.
+func hashFloat(h *maphash.Hash, f float64) {
+	if f == 0 {
+		f = 0
+	}
+	hashUint(h, math.Float64bits(f))
+}
+
+// Cost returns the current cost of the entries in the cache, across all of the
+// shards.
+func (c *ShardedCache[K, V]) Cost() Cost {
+	var cost Cost
+	for _, s := range c.shards {
+		cost += s.Cost()
+	}
+	return cost
+}
+
+// MaxCost returns the cost of entries allowed in the cache, as last set.
+func (c *ShardedCache[K, V]) MaxCost() Cost {
+	return Cost(c.maxCost.Load())
+}
+
+// SetMaxCost changes the cost of entries allowed in the cache, and splits it
+// evenly across the shards.
+//
+// Like changing TypedCache.MaxCost, the shards shrink on the next Put or Get
+// that adds an entry.
+func (c *ShardedCache[K, V]) SetMaxCost(maxCost Cost) {
+	n := Cost(len(c.shards))
+	for i, s := range c.shards {
+		share := maxCost / n
+		if Cost(i) < maxCost%n {
+			share++
+		}
+		s.SetMaxCost(max(share, 1))
+	}
+	c.maxCost.Store(int64(maxCost))
+}
+
+// Get retrieves an entry from its shard, like SyncCache.Get.
+func (c *ShardedCache[K, V]) Get(key K) (value V, err error) {
+	return c.shard(key).get(key, c.TTL)
+}
+
+// Put directly adds an entry to its shard, or refreshes an existing entry, like
+// SyncCache.Put.
+//
+// May cause evictions of other entries in the same shard.
+func (c *ShardedCache[K, V]) Put(key K, cost Cost, value V) V {
+	return c.shard(key).PutTTL(key, cost, value, c.TTL)
+}
+
+// PutTTL is like Put, but the entry expires after `ttl` instead of TTL, like
+// TypedCache.PutTTL.
+func (c *ShardedCache[K, V]) PutTTL(key K, cost Cost, value V, ttl time.Duration) V {
+	return c.shard(key).PutTTL(key, cost, value, ttl)
+}
+
+// Evict evicts a specific entry from the cache, like SyncCache.Evict.
+func (c *ShardedCache[K, V]) Evict(key K) V {
+	return c.shard(key).Evict(key)
+}
+
+// Clear evicts every entry in the cache.
+//
+// Entries added to shards that have already been cleared, while Clear is
+// running, are kept.
+func (c *ShardedCache[K, V]) Clear() {
+	for _, s := range c.shards {
+		s.Clear()
+	}
+}
+
+// Reap evicts every expired entry from the cache, one shard at a time, like
+// TypedCache.Reap.
+func (c *ShardedCache[K, V]) Reap() int {
+	n := 0
+	for _, s := range c.shards {
+		n += s.Reap()
+	}
+	return n
+}
+
+// StartJanitor starts a goroutine that calls Reap every `interval`, so expired
+// entries are evicted even if nobody asks for them. If `interval` is not
+// positive, no goroutine is started, like SyncCache.StartJanitor.
+//
+// Call the returned function to stop it.
+func (c *ShardedCache[K, V]) StartJanitor(interval time.Duration) (stop func()) {
+	return startJanitor(interval, func() { c.Reap() })
+}
@@ -0,0 +1,243 @@
+package lru
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestShardedCache(t *testing.T) {
+	// Verify entries are spread across the shards, and found again.
+	c := NewSharded[int, string](4, 100)
+	for i := 0; i < 40; i++ {
+		c.Put(i, 1, strconv.Itoa(i))
+	}
+	for i := 0; i < 40; i++ {
+		if v, err := c.Get(i); err != nil {
+			t.Errorf("unexpected error %v", err)
+		} else if got, want := v, strconv.Itoa(i); got != want {
+			t.Errorf("got %v want %v", got, want)
+		}
+	}
+	if got, want := c.Cost(), Cost(40); got != want {
+		t.Errorf("got %v want %v cost", got, want)
+	}
+	for i, s := range c.shards {
+		if s.Cost() == 0 {
+			t.Errorf("shard %d is empty", i)
+		}
+	}
+
+	if _, err := c.Get(99); err != ErrMissingEntry {
+		t.Errorf("got %v want %v", err, ErrMissingEntry)
+	}
+	if got, want := c.Evict(3), "3"; got != want {
+		t.Errorf("got %v want %v from Evict", got, want)
+	}
+	c.Clear()
+	if got, want := c.Cost(), Cost(0); got != want {
+		t.Errorf("got %v want %v cost", got, want)
+	}
+}
+
+func TestShardedCacheMaxCost(t *testing.T) {
+	// Verify the maximum cost is split across the shards, and enforced by each.
+	var evicted int
+	c := NewSharded[string, int](3, 10)
+	c.OnEvict = func(key string, value int) { evicted++ }
+	if got, want := c.MaxCost(), Cost(10); got != want {
+		t.Errorf("got %v want %v max cost", got, want)
+	}
+	var total Cost
+	for _, s := range c.shards {
+		total += s.MaxCost()
+	}
+	if got, want := total, Cost(10); got != want {
+		t.Errorf("got %v want %v total shard max cost", got, want)
+	}
+
+	for i := 0; i < 100; i++ {
+		c.Put(strconv.Itoa(i), 1, i)
+	}
+	if got, want := c.Cost(), Cost(10); got != want {
+		t.Errorf("got %v want %v cost", got, want)
+	}
+	if got, want := evicted, 90; got != want {
+		t.Errorf("got %v want %v evictions", got, want)
+	}
+
+	c.SetMaxCost(3)
+	if got, want := c.MaxCost(), Cost(3); got != want {
+		t.Errorf("got %v want %v max cost", got, want)
+	}
+}
+
+func TestShardedCacheRetrieve(t *testing.T) {
+	// Verify read-through with a custom hash.
+	type key struct{ a, b int }
+	var lock sync.Mutex
+	calls := 0
+	c := NewSharded[key, int](4, 100)
+	c.Hash = func(k key) uint64 { return uint64(k.a) }
+	c.OnRetrieve = func(k key) (int, Cost, error) {
+		lock.Lock()
+		defer lock.Unlock()
+		calls++
+		if k.a < 0 {
+			return 0, 0, fmt.Errorf("bad key %v", k)
+		}
+		return k.a + k.b, 1, nil
+	}
+
+	for i := 0; i < 2; i++ {
+		if v, err := c.Get(key{1, 2}); err != nil || v != 3 {
+			t.Errorf("got %v, %v want 3, nil", v, err)
+		}
+	}
+	if _, err := c.Get(key{-1, 0}); err == nil {
+		t.Errorf("expected error")
+	}
+	if got, want := calls, 2; got != want {
+		t.Errorf("got %v want %v calls", got, want)
+	}
+	if got := c.shard(key{5, 0}); got != c.shards[1] {
+		t.Errorf("got a different shard want shard 1 from the custom hash")
+	}
+}
+
+func TestShardedCacheDefaultHash(t *testing.T) {
+	// Verify the default hash handles keys of any type, consistently.
+	c := NewSharded[any, int](0, 100)
+	if got, want := len(c.shards), DefaultShards; got != want {
+		t.Errorf("got %v want %v shards", got, want)
+	}
+	for _, k := range []any{"s", 1, int8(1), uint64(1), 1.5, struct{ a int }{1}, [2]int{1, 2}} {
+		c.Put(k, 1, 1)
+		if _, err := c.Get(k); err != nil {
+			t.Errorf("got %v for key %#v", err, k)
+		}
+	}
+}
+
+func TestShardedCacheEqualKeys(t *testing.T) {
+	// Verify keys that are equal, but look different, find the same entry.
+	evicted := 0
+	floats := NewSharded[float64, int](64, 100)
+	floats.OnEvict = func(key float64, value int) { evicted++ }
+	floats.Put(0, 1, 1)
+	if v, err := floats.Get(math.Copysign(0, -1)); err != nil || v != 1 {
+		t.Errorf("got %v, %v want 1, nil for -0", v, err)
+	}
+	if got, want := floats.Evict(math.Copysign(0, -1)), 1; got != want {
+		t.Errorf("got %v want %v from Evict(-0)", got, want)
+	}
+	if got, want := floats.Cost(), Cost(0); got != want {
+		t.Errorf("got %v want %v cost", got, want)
+	}
+
+	// Pointers are equal by address, whatever they point to.
+	type value struct{ n int }
+	ptrs := NewSharded[*value, int](64, 100)
+	ptrs.OnEvict = func(key *value, value int) { evicted++ }
+	p := &value{1}
+	ptrs.Put(p, 1, 1)
+	for i := 2; i < 100; i++ {
+		p.n = i
+		if v, err := ptrs.Get(p); err != nil || v != 1 {
+			t.Fatalf("got %v, %v want 1, nil after changing the pointee to %d", v, err, i)
+		}
+	}
+	if got, want := ptrs.Evict(p), 1; got != want {
+		t.Errorf("got %v want %v from Evict", got, want)
+	}
+	if got, want := ptrs.Cost(), Cost(0); got != want {
+		t.Errorf("got %v want %v cost", got, want)
+	}
+	if got, want := evicted, 2; got != want {
+		t.Errorf("got %v want %v evictions", got, want)
+	}
+
+	// The same goes for keys that hold them.
+	type key struct {
+		f float64
+		p *value
+		i any
+	}
+	structs := NewSharded[key, int](64, 100)
+	structs.Put(key{0, p, p}, 1, 1)
+	p.n = -1
+	if v, err := structs.Get(key{math.Copysign(0, -1), p, p}); err != nil || v != 1 {
+		t.Errorf("got %v, %v want 1, nil for an equal struct key", v, err)
+	}
+}
+
+// benchmarkParallel runs a mix of 90% hits and 10% puts against `get` and
+// `put`, from many goroutines.
+func benchmarkParallel(b *testing.B, get func(int) (int, error), put func(int)) {
+	const keys = 1 << 12
+	for i := 0; i < keys; i++ {
+		put(i)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			i++
+			k := (i * 7919) % keys
+			if i%10 == 0 {
+				put(k)
+			} else if _, err := get(k); err != nil {
+				b.Errorf("unexpected error %v", err)
+			}
+		}
+	})
+}
+
+func BenchmarkSyncCacheParallel(b *testing.B) {
+	c := NewSync[int, int](1 << 12)
+	benchmarkParallel(b, c.Get, func(k int) { c.Put(k, 1, k) })
+}
+
+func BenchmarkShardedCacheParallel(b *testing.B) {
+	c := NewSharded[int, int](0, 1<<13) // Room for uneven shards.
+	benchmarkParallel(b, c.Get, func(k int) { c.Put(k, 1, k) })
+}
+
+func BenchmarkShardedCacheParallelStringKeys(b *testing.B) {
+	keys := make([]string, 1<<12)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+	c := NewSharded[string, int](0, 1<<13)
+	benchmarkParallel(b,
+		func(k int) (int, error) { return c.Get(keys[k]) },
+		func(k int) { c.Put(keys[k], 1, k) })
+}
+
+func TestShardedCacheTTL(t *testing.T) {
+	// Verify the TTL and clock apply to every shard.
+	clock := newFakeClock()
+	c := NewSharded[int, int](4, 100)
+	c.TTL = time.Minute
+	c.Now = clock.Now
+	c.OnRetrieve = func(key int) (int, Cost, error) { return key, 1, nil }
+	for i := 0; i < 20; i++ {
+		c.Get(i)
+	}
+	c.PutTTL(100, 1, 100, time.Hour)
+
+	clock.Advance(time.Minute)
+	if got, want := c.Reap(), 20; got != want {
+		t.Errorf("got %v want %v reaped", got, want)
+	}
+	if got, want := c.Cost(), Cost(1); got != want {
+		t.Errorf("got %v want %v cost", got, want)
+	}
+
+	stop := c.StartJanitor(time.Hour)
+	stop()
+	c.StartJanitor(0)() // No janitor, and no panic.
+}
@@ -0,0 +1,251 @@
+package lru
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SyncCache is a TypedCache that is safe for concurrent use.
+//
+// Concurrent calls to Get for the same missing key call OnRetrieve once, and
+// share the result. OnRetrieve runs without holding the lock, so a slow
+// retrieval does not hold up calls for other keys.
+type SyncCache[K comparable, V any] struct {
+	lock    sync.Mutex
+	cache   *TypedCache[K, V]
+	flights map[K]*flight[V] // Retrievals in progress.
+
+	// OnRetrieve, if not nil, is called when Get does not find an entry in the
+	// cache, and no other call to Get is already retrieving it.
+	//
+	// Must be set before the cache is used.
+	OnRetrieve TypedRetrieverFunc[K, V]
+
+	// OnEvict, if not nil, is called each time a cache entry is evicted.
+	//
+	// Called with the cache locked, so it must not use the cache.
+	//
+	// Must be set before the cache is used.
+	OnEvict TypedEvictionFunc[K, V]
+
+	// TTL, if positive, is how long entries stay in the cache, like
+	// TypedCache.TTL.
+	//
+	// Must be set before the cache is used.
+	TTL time.Duration
+
+	// Now, if not nil, returns the current time, for expiring entries, like
+	// TypedCache.Now.
+	//
+	// Must be set before the cache is used.
+	Now func() time.Time
+}
+
+// flight is a retrieval in progress.
+type flight[V any] struct {
+	done  chan struct{} // Closed when value and err are set.
+	value V
+	err   error
+
+	// stale is set if the entry was put or evicted during the retrieval, so the
+	// retrieved value is out of date, and should not be cached.
+	stale bool
+}
+
+// NewSync returns a new synchronized LRU cache with the given maximum size.
+//
+// See NewTyped for the meaning of the cost.
+func NewSync[K comparable, V any](maxCost Cost) *SyncCache[K, V] {
+	s := &SyncCache[K, V]{
+		cache:   NewTyped[K, V](maxCost),
+		flights: make(map[K]*flight[V]),
+	}
+	s.cache.OnEvict = func(key K, value V) {
+		if s.OnEvict != nil {
+			s.OnEvict(key, value)
+		}
+	}
+	s.cache.Now = func() time.Time {
+		if s.Now != nil {
+			return s.Now()
+		}
+		return time.Now()
+	}
+	return s
+}
+
+// Cost returns the current cost of the entries in the cache.
+func (s *SyncCache[K, V]) Cost() Cost {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.cache.Cost()
+}
+
+// MaxCost returns the cost of entries allowed in the cache.
+func (s *SyncCache[K, V]) MaxCost() Cost {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.cache.MaxCost
+}
+
+// SetMaxCost changes the cost of entries allowed in the cache, like setting
+// TypedCache.MaxCost.
+func (s *SyncCache[K, V]) SetMaxCost(maxCost Cost) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.cache.MaxCost = maxCost
+}
+
+// Get retrieves an entry, like TypedCache.Get.
+//
+// If the entry is missing, and another call to Get is already retrieving it,
+// waits for that call and returns the same value and error. Otherwise, calls
+// OnRetrieve without holding the lock.
+//
+// If OnRetrieve panics, the calls waiting for it return an error, and the
+// panic carries on in the calling goroutine.
+func (s *SyncCache[K, V]) Get(key K) (value V, err error) {
+	return s.get(key, s.TTL)
+}
+
+// get is Get, caching retrieved entries for `ttl`.
+func (s *SyncCache[K, V]) get(key K, ttl time.Duration) (value V, err error) {
+	s.lock.Lock()
+	if value, err = s.cache.Get(key); err == nil || s.OnRetrieve == nil {
+		s.lock.Unlock()
+		return value, err
+	}
+	if f, ok := s.flights[key]; ok {
+		s.lock.Unlock()
+		<-f.done
+		return f.value, f.err
+	}
+	f := &flight[V]{done: make(chan struct{})}
+	s.flights[key] = f
+	s.lock.Unlock()
+
+	finished := false
+	defer func() {
+		if !finished {
+			f.err = fmt.Errorf("retriever panicked for entry %v", key)
+			s.land(key, f, 0, 0)
+		}
+	}()
+	var cost Cost
+	f.value, cost, f.err = s.OnRetrieve(key)
+	finished = true
+	s.land(key, f, cost, ttl)
+	return f.value, f.err
+}
+
+// land ends a retrieval, caching its value for `ttl` if it was successful and
+// is still up to date, and wakes up the calls waiting for it.
+func (s *SyncCache[K, V]) land(key K, f *flight[V], cost Cost, ttl time.Duration) {
+	defer close(f.done)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.flights, key)
+	if f.err == nil && !f.stale {
+		s.cache.PutTTL(key, cost, f.value, ttl)
+	}
+}
+
+// Put directly adds an entry to the cache, or refreshes an existing entry, like
+// TypedCache.Put.
+//
+// If the entry is being retrieved, the retrieved value is returned to the calls
+// waiting for it, but not cached, as this one is newer.
+func (s *SyncCache[K, V]) Put(key K, cost Cost, value V) V {
+	return s.PutTTL(key, cost, value, s.TTL)
+}
+
+// PutTTL is like Put, but the entry expires after `ttl` instead of TTL, like
+// TypedCache.PutTTL.
+func (s *SyncCache[K, V]) PutTTL(key K, cost Cost, value V, ttl time.Duration) V {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if f, ok := s.flights[key]; ok {
+		f.stale = true
+	}
+	return s.cache.PutTTL(key, cost, value, ttl)
+}
+
+// Clear evicts every entry in the cache, like TypedCache.Clear.
+func (s *SyncCache[K, V]) Clear() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, f := range s.flights {
+		f.stale = true
+	}
+	s.cache.Clear()
+}
+
+// EvictOldest evicts the least recently used entry from the cache, like
+// TypedCache.EvictOldest.
+func (s *SyncCache[K, V]) EvictOldest() V {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.cache.EvictOldest()
+}
+
+// Evict evicts a specific entry from the cache, like TypedCache.Evict.
+//
+// If the entry is being retrieved, the retrieved value is returned to the calls
+// waiting for it, but not cached.
+func (s *SyncCache[K, V]) Evict(key K) V {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if f, ok := s.flights[key]; ok {
+		f.stale = true
+	}
+	return s.cache.Evict(key)
+}
+
+// Reap evicts every expired entry from the cache, like TypedCache.Reap.
+func (s *SyncCache[K, V]) Reap() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.cache.Reap()
+}
+
+// StartJanitor starts a goroutine that calls Reap every `interval`, so expired
+// entries are evicted even if nobody asks for them. If `interval` is not
+// positive, no goroutine is started, and expired entries are only evicted as
+// they are found.
+//
+// Call the returned function to stop it.
+func (s *SyncCache[K, V]) StartJanitor(interval time.Duration) (stop func()) {
+	return startJanitor(interval, func() { s.Reap() })
+}
+
+// startJanitor calls `reap` every `interval` until stopped, or never if
+// `interval` is not positive.
+func startJanitor(interval time.Duration, reap func()) (stop func()) {
+	if interval <= 0 {
+		return func() {}
+	}
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-ticker.C:
+				reap()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ticker.Stop()
+			close(done)
+			<-stopped
+		})
+	}
+}
@@ -0,0 +1,229 @@
+package lru
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSyncSingleFlight(t *testing.T) {
+	// Verify concurrent Gets for the same missing key retrieve it once.
+	var calls atomic.Int32
+	release := make(chan struct{})
+	c := NewSync[int, string](10)
+	c.OnRetrieve = func(key int) (string, Cost, error) {
+		calls.Add(1)
+		<-release
+		return fmt.Sprint(key), 1, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 10)
+	for i := range results {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.Get(7)
+			if err != nil {
+				t.Errorf("unexpected error %v", err)
+			}
+			results[i] = v
+		}()
+	}
+	time.Sleep(10 * time.Millisecond) // Let them all reach the retriever.
+	close(release)
+	wg.Wait()
+
+	if got, want := calls.Load(), int32(1); got != want {
+		t.Errorf("got %v want %v calls", got, want)
+	}
+	for _, got := range results {
+		if want := "7"; got != want {
+			t.Errorf("got %v want %v", got, want)
+		}
+	}
+	if got, want := c.Cost(), Cost(1); got != want {
+		t.Errorf("got %v want %v cost", got, want)
+	}
+}
+
+func TestSyncRetrieveOutsideLock(t *testing.T) {
+	// Verify a slow retrieval does not block hits on other keys.
+	release := make(chan struct{})
+	defer close(release)
+	c := NewSync[string, int](10)
+	c.Put("hit", 1, 1)
+	c.OnRetrieve = func(key string) (int, Cost, error) {
+		<-release
+		return 0, 1, nil
+	}
+	go c.Get("slow")
+	time.Sleep(10 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if v, err := c.Get("hit"); err != nil || v != 1 {
+			t.Errorf("got %v, %v want 1, nil", v, err)
+		}
+		c.Put("other", 1, 2)
+		c.Evict("other")
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("cache blocked by a slow retrieval")
+	}
+}
+
+func TestSyncErrorNotCached(t *testing.T) {
+	// Verify a failed retrieval is shared, but not cached.
+	calls := 0
+	c := NewSync[int, string](10)
+	c.OnRetrieve = func(key int) (string, Cost, error) {
+		calls++
+		return "", 1, fmt.Errorf("bad key %v", key)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.Get(1); err == nil {
+			t.Errorf("expected error")
+		}
+	}
+	if got, want := calls, 2; got != want {
+		t.Errorf("got %v want %v calls", got, want)
+	}
+}
+
+func TestSyncRetrieverPanics(t *testing.T) {
+	// Verify a panicking retriever fails the waiting calls, and can be retried.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	c := NewSync[int, string](10)
+	c.OnRetrieve = func(key int) (string, Cost, error) {
+		close(started)
+		<-release
+		panic("boom")
+	}
+
+	go func() {
+		defer func() { recover() }()
+		c.Get(1)
+	}()
+	<-started
+	waiter := make(chan error)
+	go func() {
+		_, err := c.Get(1)
+		waiter <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	if err := <-waiter; err == nil {
+		t.Errorf("expected error from waiting call")
+	}
+
+	c.OnRetrieve = func(key int) (string, Cost, error) { return "ok", 1, nil }
+	if v, err := c.Get(1); err != nil || v != "ok" {
+		t.Errorf("got %v, %v want ok, nil", v, err)
+	}
+}
+
+func TestSyncPutDuringRetrieval(t *testing.T) {
+	// Verify a Put during a retrieval wins over the retrieved value.
+	started := make(chan struct{})
+	release := make(chan struct{})
+	c := NewSync[int, string](10)
+	c.OnRetrieve = func(key int) (string, Cost, error) {
+		close(started)
+		<-release
+		return "retrieved", 1, nil
+	}
+
+	got := make(chan string)
+	go func() {
+		v, _ := c.Get(1)
+		got <- v
+	}()
+	<-started
+	c.Put(1, 1, "put")
+	close(release)
+	if v, want := <-got, "retrieved"; v != want {
+		t.Errorf("got %v want %v from the retrieving call", v, want)
+	}
+	if v, err := c.Get(1); err != nil || v != "put" {
+		t.Errorf("got %v, %v want put, nil", v, err)
+	}
+}
+
+func TestSyncEviction(t *testing.T) {
+	// Verify the eviction callback and cost limit work as in TypedCache.
+	var evicted []int
+	c := NewSync[int, int](2)
+	c.OnEvict = func(key int, value int) { evicted = append(evicted, key) }
+	c.Put(1, 1, 1)
+	c.Put(2, 1, 2)
+	c.Put(3, 1, 3)
+	c.SetMaxCost(1)
+	c.Put(3, 1, 3)
+	if got, want := fmt.Sprint(evicted), "[1 2]"; got != want {
+		t.Errorf("got %v want %v evicted", got, want)
+	}
+	if got, want := c.MaxCost(), Cost(1); got != want {
+		t.Errorf("got %v want %v max cost", got, want)
+	}
+	if got, want := c.EvictOldest(), 3; got != want {
+		t.Errorf("got %v want %v from EvictOldest", got, want)
+	}
+	c.Put(4, 1, 4)
+	c.Clear()
+	if got, want := c.Cost(), Cost(0); got != want {
+		t.Errorf("got %v want %v cost", got, want)
+	}
+}
+
+func TestSyncTTL(t *testing.T) {
+	// Verify entries expire, are retrieved again, and are reaped by the janitor.
+	clock := newFakeClock()
+	var lock sync.Mutex
+	var evicted []int
+	c := NewSync[int, int](10)
+	c.TTL = time.Minute
+	c.Now = clock.Now
+	c.OnRetrieve = func(key int) (int, Cost, error) { return key * 10, 1, nil }
+	c.OnEvict = func(key int, value int) {
+		lock.Lock()
+		defer lock.Unlock()
+		evicted = append(evicted, key)
+	}
+
+	c.Get(1)
+	c.Put(2, 1, 20)
+	c.PutTTL(3, 1, 30, time.Hour)
+	clock.Advance(time.Minute)
+	if v, err := c.Get(1); err != nil || v != 10 {
+		t.Errorf("got %v, %v want 10, nil", v, err)
+	}
+
+	// Without an interval, there is no janitor to stop.
+	c.StartJanitor(0)()
+	c.StartJanitor(-time.Second)()
+	if got, want := c.Cost(), Cost(3); got != want {
+		t.Errorf("got %v want %v cost without a janitor", got, want)
+	}
+
+	stop := c.StartJanitor(time.Millisecond)
+	defer stop()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Cost() != 2 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	stop() // Safe to call twice.
+
+	lock.Lock()
+	defer lock.Unlock()
+	if got, want := fmt.Sprint(evicted), "[1 2]"; got != want {
+		t.Errorf("got %v want %v evicted", got, want)
+	}
+}
@@ -0,0 +1,331 @@
+package ln
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// AccessLogFormat selects how AccessLog formats each request.
+type AccessLogFormat int
+
+const (
+	// AccessLogCommon is the Common Log Format, followed by the duration, and
+	// "panic" if the handler panicked:
+	//
+	//	10.0.0.1 - frank [18/Oct/2026:13:55:36 -0700] "GET /a.gif HTTP/1.1" 200 2326 1.5ms
+	AccessLogCommon AccessLogFormat = iota
+
+	// AccessLogCombined is the Combined Log Format, which adds the referer and
+	// user agent to the Common Log Format, followed by the duration, and
+	// "panic" if the handler panicked.
+	AccessLogCombined
+
+	// AccessLogJSON is a JSON object, with the fields of AccessRecord.
+	AccessLogJSON
+)
+
+// DefaultAccessLogLevels are the levels requests are logged at, by status
+// class (2 for 2xx, and so on), for classes missing from
+// AccessLogOptions.Levels.
+var DefaultAccessLogLevels = map[int]Level{
+	1: LevelInfo,
+	2: LevelInfo,
+	3: LevelInfo,
+	4: LevelWarning,
+	5: LevelError,
+}
+
+// AccessLogOptions controls the behavior of AccessLog.
+//
+// Zero values select the defaults.
+type AccessLogOptions struct {
+	// Format selects the format of each line. Defaults to AccessLogCommon.
+	Format AccessLogFormat
+
+	// Levels maps status classes (2 for 2xx, and so on) to the level their
+	// requests are logged at. Classes missing from the map use
+	// DefaultAccessLogLevels, and statuses outside of them, Error.
+	Levels map[int]Level
+
+	// SampleSuccess logs only one in this many 2xx requests. Others are always
+	// logged. Defaults to logging every request.
+	SampleSuccess int
+
+	// Scope is where the loggers for each level come from. Defaults to the
+	// default scope.
+	Scope *Scope
+
+	// Label replaces the function and line in the header of each message, as
+	// the lines all come from AccessLog. Defaults to "http".
+	Label string
+}
+
+// AccessRecord is what AccessLog logs about a request, and the fields of the
+// AccessLogJSON format.
+type AccessRecord struct {
+	Time       time.Time `json:"time"`
+	RemoteAddr string    `json:"remote_addr"` // Without the port.
+	User       string    `json:"user,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"` // Including the query.
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	Duration   float64   `json:"duration_ms"` // Milliseconds.
+	Referer    string    `json:"referer,omitempty"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+
+	// Panicked is set if the handler panicked. Status is still the status sent
+	// to the client, or 500 if the handler panicked before sending one.
+	Panicked bool `json:"panicked,omitempty"`
+}
+
+// AccessLog wraps `next`, logging each request it serves once it is done.
+//
+//	http.ListenAndServe(":8080", ln.AccessLog(mux, ln.AccessLogOptions{
+//		Format:        ln.AccessLogCombined,
+//		SampleSuccess: 100,
+//	}))
+//
+// Requests are logged at the level for their status class, so by default 5xx
+// responses go to Error, and 4xx to Warning. Requests whose handler panicked
+// are logged at the level for 5xx, whatever status was sent, and are never
+// sampled out.
+func AccessLog(next http.Handler, opts AccessLogOptions) http.Handler {
+	if opts.Scope == nil {
+		opts.Scope = defaultScope
+	}
+	if opts.Label == "" {
+		opts.Label = "http"
+	}
+	return &accessLog{
+		next: next,
+		opts: opts,
+		cs:   labelCallsite(opts.Label),
+	}
+}
+
+// accessLog is the handler returned by AccessLog.
+type accessLog struct {
+	next http.Handler
+	opts AccessLogOptions
+	cs   *callsite
+
+	successes atomic.Uint64 // For sampling.
+}
+
+func (a *accessLog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	rw := &accessResponseWriter{ResponseWriter: w}
+
+	// A panicking handler is logged too, as a 500 if it had not sent a status
+	// yet, and the panic carries on to the server, which drops the connection.
+	finished := false
+	defer func() {
+		if !finished {
+			if rw.status == 0 {
+				rw.status = http.StatusInternalServerError
+			}
+			a.log(r, rw, start, true)
+		}
+	}()
+	a.next.ServeHTTP(rw.wrap(), r)
+	finished = true
+	a.log(r, rw, start, false)
+}
+
+// log logs a request that started at `start`, with the response recorded by
+// `rw`.
+func (a *accessLog) log(r *http.Request, rw *accessResponseWriter, start time.Time, panicked bool) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+
+	class := rw.status / 100
+	if panicked {
+		class = 5
+	} else if class == 2 && a.opts.SampleSuccess > 1 && (a.successes.Add(1)-1)%uint64(a.opts.SampleSuccess) != 0 {
+		return
+	}
+	level, ok := a.opts.Levels[class]
+	if !ok {
+		if level, ok = DefaultAccessLogLevels[class]; !ok {
+			level = LevelError
+		}
+	}
+	lg := a.opts.Scope.Logger(level).getLogger()
+	if lg == nil {
+		return
+	}
+
+	rec := AccessRecord{
+		Time:       start,
+		RemoteAddr: r.RemoteAddr,
+		Method:     r.Method,
+		Path:       r.URL.RequestURI(),
+		Proto:      r.Proto,
+		Status:     rw.status,
+		Bytes:      rw.bytes,
+		Duration:   float64(time.Since(start)) / float64(time.Millisecond),
+		Referer:    r.Referer(),
+		UserAgent:  r.UserAgent(),
+		Panicked:   panicked,
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		rec.RemoteAddr = host
+	}
+	if user, _, ok := r.BasicAuth(); ok {
+		rec.User = user
+	}
+	lg.outputAt(a.cs, rec.format(a.opts.Format, lg.tz()))
+}
+
+// format formats the record as a log message.
+func (rec *AccessRecord) format(f AccessLogFormat, tz *time.Location) string {
+	if f == AccessLogJSON {
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return fmt.Sprintf("failed to format access record: %v", err)
+		}
+		return string(b)
+	}
+
+	t := rec.Time
+	if tz != nil {
+		t = t.In(tz)
+	}
+	msg := fmt.Sprintf("%s - %s [%s] %s %d %d",
+		dash(rec.RemoteAddr), dash(rec.User), t.Format("02/Jan/2006:15:04:05 -0700"),
+		strconv.Quote(rec.Method+" "+rec.Path+" "+rec.Proto), rec.Status, rec.Bytes)
+	if f == AccessLogCombined {
+		msg += " " + strconv.Quote(dash(rec.Referer)) + " " + strconv.Quote(dash(rec.UserAgent))
+	}
+	d := time.Duration(rec.Duration * float64(time.Millisecond))
+	msg += " " + d.Round(time.Microsecond).String()
+	if rec.Panicked {
+		msg += " panic"
+	}
+	return msg
+}
+
+// dash returns "-" for an empty field in the Common Log Format.
+func dash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// accessResponseWriter records the status and size of a response.
+//
+// It has no optional methods of its own. See wrap.
+type accessResponseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+// wrap returns `w` with the optional methods (Flush, Hijack, and ReadFrom) the
+// wrapped writer has, so handlers that check for them, like WebSocket
+// upgraders and sendfile, find the same ones they would without AccessLog.
+func (w *accessResponseWriter) wrap() http.ResponseWriter {
+	_, flush := w.ResponseWriter.(http.Flusher)
+	_, hijack := w.ResponseWriter.(http.Hijacker)
+	_, readFrom := w.ResponseWriter.(io.ReaderFrom)
+	f, h, rf := accessFlusher{w}, accessHijacker{w}, accessReaderFrom{w}
+	switch {
+	case flush && hijack && readFrom:
+		return struct {
+			*accessResponseWriter
+			http.Flusher
+			http.Hijacker
+			io.ReaderFrom
+		}{w, f, h, rf}
+	case flush && hijack:
+		return struct {
+			*accessResponseWriter
+			http.Flusher
+			http.Hijacker
+		}{w, f, h}
+	case flush && readFrom:
+		return struct {
+			*accessResponseWriter
+			http.Flusher
+			io.ReaderFrom
+		}{w, f, rf}
+	case hijack && readFrom:
+		return struct {
+			*accessResponseWriter
+			http.Hijacker
+			io.ReaderFrom
+		}{w, h, rf}
+	case flush:
+		return struct {
+			*accessResponseWriter
+			http.Flusher
+		}{w, f}
+	case hijack:
+		return struct {
+			*accessResponseWriter
+			http.Hijacker
+		}{w, h}
+	case readFrom:
+		return struct {
+			*accessResponseWriter
+			io.ReaderFrom
+		}{w, rf}
+	}
+	return w
+}
+
+func (w *accessResponseWriter) WriteHeader(status int) {
+	// Informational headers, like 103 Early Hints, come before the real one.
+	if w.status == 0 && (status >= 200 || status == http.StatusSwitchingProtocols) {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessResponseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap returns the wrapped writer, for http.ResponseController.
+func (w *accessResponseWriter) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// accessFlusher forwards Flush to a wrapped writer that has it.
+type accessFlusher struct{ w *accessResponseWriter }
+
+func (f accessFlusher) Flush() { f.w.ResponseWriter.(http.Flusher).Flush() }
+
+// accessHijacker forwards Hijack to a wrapped writer that has it.
+type accessHijacker struct{ w *accessResponseWriter }
+
+func (h accessHijacker) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return h.w.ResponseWriter.(http.Hijacker).Hijack()
+}
+
+// accessReaderFrom forwards ReadFrom to a wrapped writer that has it, counting
+// the bytes like Write.
+type accessReaderFrom struct{ w *accessResponseWriter }
+
+func (rf accessReaderFrom) ReadFrom(r io.Reader) (int64, error) {
+	if rf.w.status == 0 {
+		rf.w.status = http.StatusOK
+	}
+	n, err := rf.w.ResponseWriter.(io.ReaderFrom).ReadFrom(r)
+	rf.w.bytes += n
+	return n, err
+}
@@ -0,0 +1,311 @@
+package ln
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// accessScope returns a scope logging each level to its own sink.
+func accessScope() (*Scope, map[Level]*sink) {
+	s := NewScope()
+	s.SetTZ(time.UTC)
+	sinks := make(map[Level]*sink)
+	for _, level := range Levels() {
+		sinks[level] = newSink()
+		s.Logger(level).LogTo(sinks[level])
+	}
+	return s, sinks
+}
+
+// statusHandler responds with the status in the `status` query parameter, and
+// a body of "hello".
+var statusHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if s := r.URL.Query().Get("status"); s != "" {
+		var status int
+		fmt.Sscan(s, &status)
+		w.WriteHeader(status)
+	}
+	fmt.Fprint(w, "hello")
+})
+
+func serve(h http.Handler, target string) {
+	r := httptest.NewRequest("GET", target, nil)
+	r.RemoteAddr = "10.0.0.1:1234"
+	r.Header.Set("Referer", "http://example.com/")
+	r.Header.Set("User-Agent", "test/1.0")
+	r.SetBasicAuth("frank", "secret")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+}
+
+func TestAccessLogFormats(t *testing.T) {
+	tests := []struct {
+		format AccessLogFormat
+		want   string // Regexp for the message, after the header.
+	}{
+		{AccessLogCommon, `^10\.0\.0\.1 - frank \[\d\d/\w{3}/\d{4}:\d\d:\d\d:\d\d \+0000\] "GET /a\?status=200 HTTP/1\.1" 200 5 [0-9.]+[µm]?s$`},
+		{AccessLogCombined, `^10\.0\.0\.1 - frank \[.*\] "GET /a\?status=200 HTTP/1\.1" 200 5 "http://example\.com/" "test/1\.0" [0-9.]+[µm]?s$`},
+	}
+	for _, tc := range tests {
+		s, sinks := accessScope()
+		serve(AccessLog(statusHandler, AccessLogOptions{Format: tc.format, Scope: s}), "/a?status=200")
+
+		line := strings.TrimSuffix(sinks[LevelInfo].String(), "\n")
+		header, msg, _ := strings.Cut(line, " http ")
+		if !strings.HasPrefix(header, "I") {
+			t.Errorf("got header %q want an Info header labeled http", header)
+		}
+		if !regexp.MustCompile(tc.want).MatchString(msg) {
+			t.Errorf("format %d: got %q want match for %s", tc.format, msg, tc.want)
+		}
+	}
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	s, sinks := accessScope()
+	serve(AccessLog(statusHandler, AccessLogOptions{Format: AccessLogJSON, Scope: s}), "/b")
+
+	_, msg, _ := strings.Cut(strings.TrimSuffix(sinks[LevelInfo].String(), "\n"), " http ")
+	var got AccessRecord
+	if err := json.Unmarshal([]byte(msg), &got); err != nil {
+		t.Fatalf("failed to parse %q: %v", msg, err)
+	}
+	want := AccessRecord{
+		RemoteAddr: "10.0.0.1",
+		User:       "frank",
+		Method:     "GET",
+		Path:       "/b",
+		Proto:      "HTTP/1.1",
+		Status:     200,
+		Bytes:      5,
+		Referer:    "http://example.com/",
+		UserAgent:  "test/1.0",
+	}
+	got.Time, got.Duration = time.Time{}, 0
+	if got != want {
+		t.Errorf("got %+v want %+v", got, want)
+	}
+}
+
+func TestAccessLogLevels(t *testing.T) {
+	s, sinks := accessScope()
+	h := AccessLog(statusHandler, AccessLogOptions{Scope: s, Levels: map[int]Level{3: LevelDebug}})
+	for _, status := range []int{200, 302, 404, 503} {
+		serve(h, fmt.Sprintf("/?status=%d", status))
+	}
+
+	for level, want := range map[Level]string{
+		LevelInfo:    " 200 ",
+		LevelDebug:   " 302 ",
+		LevelWarning: " 404 ",
+		LevelError:   " 503 ",
+	} {
+		got := sinks[level].String()
+		if strings.Count(got, "\n") != 1 || !strings.Contains(got, want) {
+			t.Errorf("got %s messages %q want one with %q", level, got, want)
+		}
+	}
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	s, sinks := accessScope()
+	h := AccessLog(statusHandler, AccessLogOptions{Scope: s, SampleSuccess: 10})
+	for i := 0; i < 25; i++ {
+		serve(h, "/")
+		serve(h, "/?status=500")
+	}
+
+	if got, want := strings.Count(sinks[LevelInfo].String(), "\n"), 3; got != want {
+		t.Errorf("got %d 2xx messages want %d", got, want)
+	}
+	if got, want := strings.Count(sinks[LevelError].String(), "\n"), 25; got != want {
+		t.Errorf("got %d 5xx messages want %d", got, want)
+	}
+}
+
+// TestAccessLogFlush verifies the wrapped writer can still be flushed.
+func TestAccessLogFlush(t *testing.T) {
+	s, _ := accessScope()
+	rec := httptest.NewRecorder()
+	h := AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusEarlyHints)
+		w.WriteHeader(http.StatusAccepted)
+		if err := http.NewResponseController(w).Flush(); err != nil {
+			t.Errorf("failed to flush: %v", err)
+		}
+	}), AccessLogOptions{Scope: s})
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if !rec.Flushed {
+		t.Errorf("got unflushed response want flushed")
+	}
+}
+
+// TestAccessLogPanic verifies a request whose handler panics is still logged,
+// as a 500, and the panic carries on.
+func TestAccessLogPanic(t *testing.T) {
+	s, sinks := accessScope()
+	h := AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("handler failed")
+	}), AccessLogOptions{Scope: s})
+
+	func() {
+		defer func() {
+			if got, want := recover(), "handler failed"; got != want {
+				t.Errorf("got %v want %v from the handler", got, want)
+			}
+		}()
+		serve(h, "/panic")
+	}()
+	if got := sinks[LevelError].String(); !strings.Contains(got, `"GET /panic HTTP/1.1" 500 `) || !strings.HasSuffix(got, " panic\n") {
+		t.Errorf("got %q want a 500 marked as a panic for the panicking request", got)
+	}
+}
+
+// TestAccessLogPanicAfterStatus verifies a handler that panics after sending a
+// status is logged with that status, marked as a panic, and at Error, even when
+// its status class would be sampled.
+func TestAccessLogPanicAfterStatus(t *testing.T) {
+	s, sinks := accessScope()
+	h := AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "partial")
+		panic("handler failed")
+	}), AccessLogOptions{Format: AccessLogJSON, Scope: s, SampleSuccess: 1000})
+
+	for i := 0; i < 2; i++ {
+		func() {
+			defer func() { recover() }()
+			serve(h, "/partial")
+		}()
+	}
+	lines := strings.Split(strings.TrimSuffix(sinks[LevelError].String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %q want 2 Error messages", lines)
+	}
+	for _, line := range lines {
+		_, msg, _ := strings.Cut(line, " http ")
+		var got AccessRecord
+		if err := json.Unmarshal([]byte(msg), &got); err != nil {
+			t.Fatalf("failed to parse %q: %v", msg, err)
+		}
+		if got.Status != http.StatusOK || got.Bytes != 7 || !got.Panicked {
+			t.Errorf("got %+v want status 200, 7 bytes, and panicked", got)
+		}
+	}
+	if got := sinks[LevelInfo].String(); got != "" {
+		t.Errorf("got %q want no Info messages", got)
+	}
+}
+
+// plainWriter is a ResponseWriter with none of the optional methods.
+type plainWriter struct {
+	header http.Header
+	body   strings.Builder
+}
+
+func (w *plainWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = make(http.Header)
+	}
+	return w.header
+}
+func (w *plainWriter) Write(p []byte) (int, error) { return w.body.Write(p) }
+func (w *plainWriter) WriteHeader(int)             {}
+
+// readerFromWriter is a ResponseWriter with ReadFrom, which counts its calls.
+type readerFromWriter struct {
+	plainWriter
+	readFroms int
+}
+
+func (w *readerFromWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.readFroms++
+	return io.Copy(&w.body, r)
+}
+
+// TestAccessLogOptionalMethods verifies the writer passed to the handler has
+// the optional methods of the wrapped writer, and only those.
+func TestAccessLogOptionalMethods(t *testing.T) {
+	tests := []struct {
+		name                    string
+		w                       http.ResponseWriter
+		flush, hijack, readFrom bool
+	}{
+		{"plain", &plainWriter{}, false, false, false},
+		{"recorder", httptest.NewRecorder(), true, false, false},
+		{"readerFrom", &readerFromWriter{}, false, false, true},
+	}
+	for _, tc := range tests {
+		s, _ := accessScope()
+		h := AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, flush := w.(http.Flusher)
+			_, hijack := w.(http.Hijacker)
+			_, readFrom := w.(io.ReaderFrom)
+			if flush != tc.flush || hijack != tc.hijack || readFrom != tc.readFrom {
+				t.Errorf("%s: got Flush %v, Hijack %v, ReadFrom %v want %v, %v, %v",
+					tc.name, flush, hijack, readFrom, tc.flush, tc.hijack, tc.readFrom)
+			}
+		}), AccessLogOptions{Scope: s})
+		h.ServeHTTP(tc.w, httptest.NewRequest("GET", "/", nil))
+	}
+}
+
+// TestAccessLogReadFrom verifies ReadFrom reaches the wrapped writer, and its
+// bytes are counted.
+func TestAccessLogReadFrom(t *testing.T) {
+	s, sinks := accessScope()
+	w := &readerFromWriter{}
+	h := AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(io.ReaderFrom).ReadFrom(strings.NewReader("hello"))
+	}), AccessLogOptions{Scope: s})
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if got, want := w.readFroms, 1; got != want {
+		t.Errorf("got %d want %d calls to ReadFrom", got, want)
+	}
+	if got := sinks[LevelInfo].String(); !strings.Contains(got, " 200 5 ") {
+		t.Errorf("got %q want 200 with 5 bytes", got)
+	}
+}
+
+// TestAccessLogHijack verifies a handler behind AccessLog can take over the
+// connection, as WebSocket upgraders do.
+func TestAccessLogHijack(t *testing.T) {
+	s, sinks := accessScope()
+	h := AccessLog(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, buf, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("failed to hijack: %v", err)
+			return
+		}
+		defer conn.Close()
+		buf.WriteString("HTTP/1.1 200 OK\r\nContent-Length: 8\r\nConnection: close\r\n\r\nhijacked")
+		buf.Flush()
+	}), AccessLogOptions{Scope: s})
+	// The server does not wait for hijacked connections when closed.
+	done := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(done)
+		h.ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if got, want := string(body), "hijacked"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+	<-done
+	if got := sinks[LevelInfo].String(); !strings.Contains(got, `"GET / HTTP/1.1"`) {
+		t.Errorf("got %q want the hijacked request logged", got)
+	}
+}
@@ -0,0 +1,430 @@
+package ln
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCheckpointEvery is the number of records between checkpoints, if
+// AuditOptions.CheckpointEvery is not set.
+const DefaultCheckpointEvery = 1000
+
+// checkpointMarker begins each checkpoint line in an audit log.
+const checkpointMarker = "#checkpoint "
+
+// AuditSigner signs the checkpoints in an audit log.
+type AuditSigner interface {
+	Sign(msg []byte) ([]byte, error)
+}
+
+// AuditVerifier checks the signatures on the checkpoints in an audit log.
+type AuditVerifier interface {
+	Verify(msg, sig []byte) bool
+}
+
+// HMACKey is an AuditSigner and AuditVerifier using HMAC-SHA256.
+//
+// The same key is needed to write and verify the log, so anybody who can
+// verify it can also forge it.
+type HMACKey []byte
+
+// Sign returns the HMAC-SHA256 of `msg`.
+func (k HMACKey) Sign(msg []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, k)
+	mac.Write(msg)
+	return mac.Sum(nil), nil
+}
+
+// Verify reports whether `sig` is the HMAC-SHA256 of `msg`.
+func (k HMACKey) Verify(msg, sig []byte) bool {
+	want, _ := k.Sign(msg)
+	return hmac.Equal(sig, want)
+}
+
+// Ed25519Signer is an AuditSigner using an Ed25519 private key.
+type Ed25519Signer ed25519.PrivateKey
+
+// Sign returns the Ed25519 signature of `msg`.
+func (k Ed25519Signer) Sign(msg []byte) ([]byte, error) {
+	if len(k) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("bad Ed25519 private key size %d", len(k))
+	}
+	return ed25519.Sign(ed25519.PrivateKey(k), msg), nil
+}
+
+// Ed25519Verifier is an AuditVerifier using an Ed25519 public key.
+type Ed25519Verifier ed25519.PublicKey
+
+// Verify reports whether `sig` is a valid Ed25519 signature of `msg`.
+func (k Ed25519Verifier) Verify(msg, sig []byte) bool {
+	if len(k) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(ed25519.PublicKey(k), msg, sig)
+}
+
+// AuditOptions controls the behavior of an AuditWriter.
+//
+// Zero values select the defaults.
+type AuditOptions struct {
+	// CheckpointEvery is the number of records between checkpoints. Defaults to
+	// DefaultCheckpointEvery.
+	CheckpointEvery int
+}
+
+// AuditWriter is a sink that writes a tamper-evident audit log.
+//
+// Each message is written as one record line, holding the SHA-256 hash of the
+// previous record's hash followed by the message:
+//
+//	<hash> <message>
+//
+// Newlines and backslashes inside the message are escaped, so every record is
+// one line. The chain starts from a hash of all zeros.
+//
+// Every so often, and on Flush and Close, a checkpoint line signs the number of
+// records so far and the latest hash:
+//
+//	#checkpoint <records> <hash> <time> <signature>
+//
+// Only records covered by a signed checkpoint are protected. The chain alone
+// uses no key, so anybody who can write the file can edit it and recompute the
+// hashes; what they cannot do without the signing key is make a checkpoint
+// that matches. Editing, inserting, or removing a record before the last
+// checkpoint breaks the chain or that checkpoint. Records after the last
+// checkpoint can be edited or removed without a trace, and a log with every
+// checkpoint removed proves nothing, so given a verifier, VerifyAudit fails a
+// log with records after its last checkpoint or with no checkpoints. A log
+// that was closed cleanly always ends with a checkpoint, even if it is empty.
+//
+// The log cannot tell how long it should be, though. Removing records from the
+// end along with the checkpoints that cover them leaves a shorter log that
+// still verifies. To catch that, keep the record count of a recent checkpoint
+// somewhere the log's writer cannot change, and pass it to VerifyAuditWith as
+// AuditExpect.MinRecords.
+//
+// Use VerifyAudit, or the lnaudit command, to check a log.
+//
+// Safe for concurrent use.
+type AuditWriter struct {
+	w       io.Writer
+	signer  AuditSigner
+	every   int
+	now     func() time.Time // Replaceable for testing.
+	lock    sync.Mutex
+	buf     []byte
+	hash    [sha256.Size]byte
+	records int
+	signed  int  // Records covered by the last checkpoint.
+	checked bool // Whether there is a checkpoint yet.
+}
+
+// NewAuditWriter returns an AuditWriter that starts a new audit log on `w`.
+func NewAuditWriter(w io.Writer, signer AuditSigner, opts AuditOptions) *AuditWriter {
+	if opts.CheckpointEvery <= 0 {
+		opts.CheckpointEvery = DefaultCheckpointEvery
+	}
+	return &AuditWriter{
+		w:      w,
+		signer: signer,
+		every:  opts.CheckpointEvery,
+		now:    time.Now,
+	}
+}
+
+// OpenAuditFile returns an AuditWriter that appends to the audit log in the
+// file at `path`, creating the file if necessary.
+//
+// An existing log is checked with VerifyAudit (without checking signatures)
+// first, and its chain continued. Returns an error instead of extending a
+// broken chain.
+func OpenAuditFile(path string, signer AuditSigner, opts AuditOptions) (*AuditWriter, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	res, err := VerifyAudit(f, nil)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("existing audit log %s: %w", path, err)
+	}
+
+	w := NewAuditWriter(f, signer, opts)
+	w.hash = res.Hash
+	w.records = res.Records
+	w.signed = res.Records - res.Unsigned
+	w.checked = res.Checkpoints > 0
+	return w, nil
+}
+
+// Unwrap returns the writer the log is written to.
+func (w *AuditWriter) Unwrap() io.Writer { return w.w }
+
+// Write adds `p` to the log as one record, followed by a checkpoint if one is
+// due.
+func (w *AuditWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	b := appendAuditEscaped(w.buf[:0], bytes.TrimSuffix(p, []byte("\n")))
+	hash := chainHash(w.hash, b)
+
+	line := make([]byte, 0, hex.EncodedLen(len(hash))+len(b)+2)
+	line = hex.AppendEncode(line, hash[:])
+	line = append(line, ' ')
+	line = append(line, b...)
+	line = append(line, '\n')
+	w.buf = b
+	if _, err := w.w.Write(line); err != nil {
+		return 0, err
+	}
+	w.hash = hash
+	w.records++
+
+	if w.records-w.signed >= w.every {
+		if err := w.checkpoint(); err != nil {
+			return len(p), err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes a checkpoint, if there are records since the last one or there
+// is no checkpoint yet, and flushes the underlying writer if it buffers.
+func (w *AuditWriter) Flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.records > w.signed || !w.checked {
+		if err := w.checkpoint(); err != nil {
+			return err
+		}
+	}
+	if f, ok := w.w.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Sync syncs the underlying writer to stable storage, if it supports syncing.
+func (w *AuditWriter) Sync() error {
+	if s, ok := w.w.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+// Close writes a final checkpoint, and closes the underlying writer if it is
+// an io.Closer.
+func (w *AuditWriter) Close() error {
+	err := w.Flush()
+	if c, ok := w.w.(io.Closer); ok {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// checkpoint writes a signed checkpoint for the current state.
+//
+// The lock must be held.
+func (w *AuditWriter) checkpoint() error {
+	msg := checkpointMessage(w.records, w.hash, w.now())
+	sig, err := w.signer.Sign([]byte(msg))
+	if err != nil {
+		return fmt.Errorf("signing audit checkpoint: %w", err)
+	}
+	line := checkpointMarker + msg + " " + base64.StdEncoding.EncodeToString(sig) + "\n"
+	if _, err := io.WriteString(w.w, line); err != nil {
+		return err
+	}
+	w.signed = w.records
+	w.checked = true
+	return nil
+}
+
+// checkpointMessage returns the signed part of a checkpoint line.
+func checkpointMessage(records int, hash [sha256.Size]byte, t time.Time) string {
+	return strconv.Itoa(records) + " " + hex.EncodeToString(hash[:]) + " " + t.UTC().Format(time.RFC3339Nano)
+}
+
+// chainHash returns the hash of a record following one with hash `prev`.
+func chainHash(prev [sha256.Size]byte, record []byte) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(prev[:])
+	h.Write(record)
+	var sum [sha256.Size]byte
+	h.Sum(sum[:0])
+	return sum
+}
+
+// appendAuditEscaped appends `p` to `b`, escaping backslashes and newlines.
+func appendAuditEscaped(b, p []byte) []byte {
+	for _, c := range p {
+		switch c {
+		case '\\':
+			b = append(b, '\\', '\\')
+		case '\n':
+			b = append(b, '\\', 'n')
+		default:
+			b = append(b, c)
+		}
+	}
+	return b
+}
+
+// AuditResult describes an audit log checked by VerifyAudit.
+type AuditResult struct {
+	Records     int
+	Checkpoints int
+
+	// Unsigned is the number of records after the last checkpoint. Records
+	// removed from the end of those cannot be detected.
+	Unsigned int
+
+	// Hash is the hash of the last record.
+	Hash [sha256.Size]byte
+}
+
+// AuditExpect is what is known about an audit log from elsewhere, for
+// VerifyAuditWith to check it against.
+//
+// Zero values expect nothing.
+type AuditExpect struct {
+	// MinRecords is the least number of records the last checkpoint should
+	// cover, like the count from a checkpoint noted down earlier.
+	MinRecords int
+
+	// MinCheckpoints is the least number of checkpoints the log should have.
+	MinCheckpoints int
+}
+
+// ErrAuditTruncated is returned (wrapped) by VerifyAuditWith for a log that is
+// shorter than expected.
+var ErrAuditTruncated = errors.New("audit log is shorter than expected")
+
+// ErrAuditUnsigned is returned (wrapped) by VerifyAudit for a log that has
+// records after its last checkpoint, or no checkpoints at all, when checking
+// signatures.
+//
+// Those records could have been edited or removed without a trace. A log that
+// is still being written usually has some; callers that accept them can check
+// for this error with errors.Is, and use the AuditResult returned with it.
+var ErrAuditUnsigned = errors.New("not covered by a signed checkpoint")
+
+// AuditError describes the first broken link found by VerifyAudit.
+type AuditError struct {
+	Line   int // Starting from 1.
+	Reason string
+}
+
+func (e *AuditError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Reason)
+}
+
+// VerifyAudit reads an audit log written by an AuditWriter, and checks the
+// hash chain and the checkpoints.
+//
+// Checkpoint signatures are checked with `v`, unless it is nil. With a
+// verifier, a log that is otherwise intact but has records after its last
+// checkpoint, or no checkpoints, fails with ErrAuditUnsigned. Without one, only
+// the chain is checked, which anybody can recompute.
+//
+// A log cut short at a checkpoint still verifies. Use VerifyAuditWith to check
+// its length against what is known from elsewhere.
+//
+// Returns an *AuditError for the first broken link, or any error from reading.
+func VerifyAudit(r io.Reader, v AuditVerifier) (AuditResult, error) {
+	return VerifyAuditWith(r, v, AuditExpect{})
+}
+
+// VerifyAuditWith is like VerifyAudit, but also fails with ErrAuditTruncated
+// if the log falls short of `expect`.
+func VerifyAuditWith(r io.Reader, v AuditVerifier, expect AuditExpect) (AuditResult, error) {
+	var res AuditResult
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadString('\n')
+		if err == io.EOF && line == "" {
+			switch signed := res.Records - res.Unsigned; {
+			case signed < expect.MinRecords:
+				return res, fmt.Errorf("last checkpoint covers %d records, want at least %d: %w", signed, expect.MinRecords, ErrAuditTruncated)
+			case res.Checkpoints < expect.MinCheckpoints:
+				return res, fmt.Errorf("%d checkpoints, want at least %d: %w", res.Checkpoints, expect.MinCheckpoints, ErrAuditTruncated)
+			case v == nil:
+			case res.Checkpoints == 0:
+				return res, fmt.Errorf("no checkpoints, so %d records are %w", res.Records, ErrAuditUnsigned)
+			case res.Unsigned > 0:
+				return res, fmt.Errorf("%d records after the last checkpoint are %w", res.Unsigned, ErrAuditUnsigned)
+			}
+			return res, nil
+		}
+		if err != nil && err != io.EOF {
+			return res, err
+		}
+		fail := func(format string, a ...any) (AuditResult, error) {
+			return res, &AuditError{Line: n, Reason: fmt.Sprintf(format, a...)}
+		}
+		if !strings.HasSuffix(line, "\n") {
+			return fail("truncated line")
+		}
+		line = line[:len(line)-1]
+
+		if rest, ok := strings.CutPrefix(line, checkpointMarker); ok {
+			msg, sig64, ok := cutLast(rest, " ")
+			fields := strings.Split(msg, " ")
+			if !ok || len(fields) != 3 {
+				return fail("malformed checkpoint")
+			}
+			if fields[0] != strconv.Itoa(res.Records) {
+				return fail("checkpoint covers %s records, but %d precede it", fields[0], res.Records)
+			}
+			if fields[1] != hex.EncodeToString(res.Hash[:]) {
+				return fail("checkpoint hash does not match the preceding record")
+			}
+			if v != nil {
+				sig, err := base64.StdEncoding.DecodeString(sig64)
+				if err != nil || !v.Verify([]byte(msg), sig) {
+					return fail("bad checkpoint signature")
+				}
+			}
+			res.Checkpoints++
+			res.Unsigned = 0
+			continue
+		}
+
+		hash64, record, ok := strings.Cut(line, " ")
+		var hash [sha256.Size]byte
+		if !ok || hex.DecodedLen(len(hash64)) != len(hash) {
+			return fail("malformed record")
+		}
+		if _, err := hex.Decode(hash[:], []byte(hash64)); err != nil {
+			return fail("malformed record")
+		}
+		if hash != chainHash(res.Hash, []byte(record)) {
+			return fail("record hash does not follow from the previous record")
+		}
+		res.Hash = hash
+		res.Records++
+		res.Unsigned++
+	}
+}
+
+// cutLast slices `s` around the last instance of `sep`.
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,196 @@
+package ln
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeAudit writes `msgs` to a new audit log, and returns its contents.
+func writeAudit(t *testing.T, signer AuditSigner, every int, msgs ...string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewAuditWriter(&buf, signer, AuditOptions{CheckpointEvery: every})
+	w.now = func() time.Time { return time.Unix(1700000000, 0) }
+	for _, m := range msgs {
+		if _, err := w.Write([]byte(m)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf.String()
+}
+
+func TestAuditRoundTrip(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name   string
+		signer AuditSigner
+		v      AuditVerifier
+	}{
+		{"hmac", HMACKey("secret"), HMACKey("secret")},
+		{"ed25519", Ed25519Signer(priv), Ed25519Verifier(pub)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			log := writeAudit(t, tc.signer, 2, "I one\n", "I two\nmore \\ lines\n", "I three\n")
+			res, err := VerifyAudit(strings.NewReader(log), tc.v)
+			if err != nil {
+				t.Fatalf("VerifyAudit: %v\n%s", err, log)
+			}
+			if res.Records != 3 || res.Checkpoints != 2 || res.Unsigned != 0 {
+				t.Errorf("got %+v want 3 records, 2 checkpoints, 0 unsigned", res)
+			}
+			if got, want := strings.Count(log, "\n"), 5; got != want {
+				t.Errorf("got %d want %d lines:\n%s", got, want, log)
+			}
+		})
+	}
+}
+
+func TestAuditTampering(t *testing.T) {
+	key := HMACKey("secret")
+	log := writeAudit(t, key, 2, "I one\n", "I two\n", "I three\n", "I four\n", "I five\n")
+	lines := strings.SplitAfter(log, "\n")
+	lines = lines[:len(lines)-1] // After the final newline.
+
+	// Line numbers are 1-based; lines 3, 6, and 8 are checkpoints.
+	for _, tc := range []struct {
+		name   string
+		edit   func(ls []string) []string
+		line   int
+		reason string
+	}{
+		{"edit", func(ls []string) []string {
+			ls[1] = strings.Replace(ls[1], "two", "TWO", 1)
+			return ls
+		}, 2, "record hash"},
+		{"remove record", func(ls []string) []string {
+			return append(ls[:3], ls[4:]...)
+		}, 4, "record hash"},
+		{"swap records", func(ls []string) []string {
+			ls[0], ls[1] = ls[1], ls[0]
+			return ls
+		}, 1, "record hash"},
+		{"forged checkpoint", func(ls []string) []string {
+			ls[2] = ls[2][:len(ls[2])-5] + "AAAA\n"
+			return ls
+		}, 3, "signature"},
+		{"truncated line", func(ls []string) []string {
+			ls[len(ls)-1] = strings.TrimSuffix(ls[len(ls)-1], "\n")
+			return ls
+		}, 8, "truncated"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			edited := strings.Join(tc.edit(append([]string(nil), lines...)), "")
+			_, err := VerifyAudit(strings.NewReader(edited), key)
+			var ae *AuditError
+			if !errors.As(err, &ae) {
+				t.Fatalf("got %v want an *AuditError", err)
+			}
+			if ae.Line != tc.line || !strings.Contains(ae.Reason, tc.reason) {
+				t.Errorf("got %v want line %d: ...%s...", err, tc.line, tc.reason)
+			}
+		})
+	}
+
+	// Removing records from the end is visible as unsigned records, which fail
+	// with a verifier and pass without one.
+	res, err := VerifyAudit(strings.NewReader(strings.Join(lines[:4], "")), key)
+	if !errors.Is(err, ErrAuditUnsigned) || res.Unsigned != 1 {
+		t.Errorf("got %+v, %v want 1 unsigned record and ErrAuditUnsigned", res, err)
+	}
+	if _, err := VerifyAudit(strings.NewReader(strings.Join(lines[:4], "")), nil); err != nil {
+		t.Errorf("unexpected error from VerifyAudit without a verifier: %v", err)
+	}
+
+	// Removing every checkpoint leaves a valid chain, which is not enough.
+	var stripped []string
+	for _, l := range lines {
+		if !strings.HasPrefix(l, checkpointMarker) {
+			stripped = append(stripped, l)
+		}
+	}
+	res, err = VerifyAudit(strings.NewReader(strings.Join(stripped, "")), key)
+	if !errors.Is(err, ErrAuditUnsigned) || res.Records != 5 || res.Checkpoints != 0 {
+		t.Errorf("got %+v, %v want 5 records, no checkpoints, and ErrAuditUnsigned", res, err)
+	}
+
+	// Cutting the log at a checkpoint leaves one that verifies, unless its
+	// length is known from elsewhere.
+	cut := strings.Join(lines[:6], "")
+	res, err = VerifyAudit(strings.NewReader(cut), key)
+	if err != nil || res.Records != 4 {
+		t.Errorf("got %+v, %v want 4 records and no error for a log cut at a checkpoint", res, err)
+	}
+	for _, expect := range []AuditExpect{{MinRecords: 5}, {MinCheckpoints: 3}} {
+		if _, err := VerifyAuditWith(strings.NewReader(cut), key, expect); !errors.Is(err, ErrAuditTruncated) {
+			t.Errorf("got %v want ErrAuditTruncated expecting %+v", err, expect)
+		}
+	}
+	if _, err := VerifyAuditWith(strings.NewReader(log), key, AuditExpect{MinRecords: 5, MinCheckpoints: 3}); err != nil {
+		t.Errorf("unexpected error from VerifyAuditWith for the whole log: %v", err)
+	}
+
+	// The wrong key fails at the first checkpoint.
+	if _, err := VerifyAudit(strings.NewReader(log), HMACKey("other")); err == nil || !strings.Contains(err.Error(), "line 3") {
+		t.Errorf("got %v want an error at line 3", err)
+	}
+}
+
+// TestAuditEmpty verifies a cleanly closed log without records still has a
+// checkpoint, so it cannot be confused with one that was emptied.
+func TestAuditEmpty(t *testing.T) {
+	key := HMACKey("secret")
+	log := writeAudit(t, key, 2)
+	res, err := VerifyAudit(strings.NewReader(log), key)
+	if err != nil || res.Records != 0 || res.Checkpoints != 1 {
+		t.Errorf("got %+v, %v want 0 records and 1 checkpoint:\n%s", res, err, log)
+	}
+	if _, err := VerifyAudit(strings.NewReader(""), key); !errors.Is(err, ErrAuditUnsigned) {
+		t.Errorf("got %v want ErrAuditUnsigned for an emptied log", err)
+	}
+}
+
+func TestOpenAuditFile(t *testing.T) {
+	key := HMACKey("secret")
+	path := filepath.Join(t.TempDir(), "audit.log")
+
+	for i, msg := range []string{"I first run\n", "I second run\n"} {
+		w, err := OpenAuditFile(path, key, AuditOptions{})
+		if err != nil {
+			t.Fatalf("run %d: OpenAuditFile: %v", i, err)
+		}
+		l := NewLevel(LevelInfo, w, nil)
+		l.Print(msg)
+		if err := w.Close(); err != nil {
+			t.Fatalf("run %d: Close: %v", i, err)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := VerifyAudit(bytes.NewReader(b), key)
+	if err != nil || res.Records != 2 || res.Checkpoints != 2 {
+		t.Errorf("got %+v, %v want 2 records and 2 checkpoints:\n%s", res, err, b)
+	}
+
+	// A broken chain is not extended.
+	if err := os.WriteFile(path, bytes.Replace(b, []byte("first"), []byte("FIRST"), 1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenAuditFile(path, key, AuditOptions{}); err == nil {
+		t.Errorf("expected error opening a broken audit log")
+	}
+}
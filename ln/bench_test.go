@@ -0,0 +1,77 @@
+package ln
+
+import (
+	"fmt"
+	"io"
+	"testing"
+)
+
+// benchLogging points the package loggers at io.Discard for the duration of a
+// benchmark.
+func benchLogging(b *testing.B) func() {
+	snap := Snapshot()
+	LogAllTo(io.Discard)
+	Verbosity = 0
+	b.ReportAllocs()
+	b.ResetTimer()
+	return snap.Restore
+}
+
+func BenchmarkPrint(b *testing.B) {
+	defer benchLogging(b)()
+	for i := 0; i < b.N; i++ {
+		Info.Print("benchmark message ", i)
+	}
+}
+
+func BenchmarkPrintf(b *testing.B) {
+	defer benchLogging(b)()
+	for i := 0; i < b.N; i++ {
+		Info.Printf("benchmark message %d", i)
+	}
+}
+
+func BenchmarkCall(b *testing.B) {
+	defer benchLogging(b)()
+	for i := 0; i < b.N; i++ {
+		Info("benchmark message")
+	}
+}
+
+func BenchmarkPrintfParallel(b *testing.B) {
+	defer benchLogging(b)()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			Info.Printf("benchmark message %d", i)
+		}
+	})
+}
+
+func BenchmarkVDisabled(b *testing.B) {
+	defer benchLogging(b)()
+	for i := 0; i < b.N; i++ {
+		V(3).Printf("benchmark message %d", i)
+	}
+}
+
+func BenchmarkVDisabledPackageVerbosity(b *testing.B) {
+	defer benchLogging(b)()
+	PackageVerbosity["other"] = 5
+	for i := 0; i < b.N; i++ {
+		V(3).Printf("benchmark message %d", i)
+	}
+}
+
+func BenchmarkVDisabledFunc(b *testing.B) {
+	defer benchLogging(b)()
+	for i := 0; i < b.N; i++ {
+		V(3).Func(func() string { return fmt.Sprintf("benchmark message %d", i) })
+	}
+}
+
+func BenchmarkVEnabledFunc(b *testing.B) {
+	defer benchLogging(b)()
+	for i := 0; i < b.N; i++ {
+		V(0).Func(func() string { return "benchmark message" })
+	}
+}
@@ -0,0 +1,133 @@
+package ln
+
+import (
+	"path"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// callsite holds the details of a logging callsite, computed once per program
+// counter and cached.
+type callsite struct {
+	fullFile string // Full path of the file.
+	file     string // Base name of the file.
+	line     int
+	fullFnc  string // Full function name, like path/to/pkg.Func.
+	fnc      string // Function name without path or package, like Func.
+
+	longPkg, shortPkg string // Like path/to/pkg, and pkg.
+
+	vmodule atomic.Pointer[vmoduleMatch] // Cached by moduleVerbosity.
+
+	// Set by record, for the callsite registry.
+	kind   atomic.Int32              // A callsiteKind.
+	vlevel atomic.Int32              // Level of the last V call.
+	rule   atomic.Pointer[ruleMatch] // Cached by state.
+
+	// label replaces `fnc(file:line)` in the header, if set. Used for messages
+	// that do not come from a call in this program, like lines read from a
+	// subprocess.
+	label string
+}
+
+// labelCallsite returns a callsite that shows up in headers as `label`.
+func labelCallsite(label string) *callsite {
+	return &callsite{label: label}
+}
+
+// callsites maps program counters onto their *callsite.
+var callsites sync.Map
+
+// callsiteAt returns the callsite of the caller.
+//
+// Jumps back `skip` frames (0 = caller of `callsiteAt`).
+//
+// Returns nil if the callsite cannot be determined.
+func callsiteAt(skip int) *callsite {
+	var pcs [1]uintptr
+	if runtime.Callers(skip+2, pcs[:]) == 0 {
+		return nil
+	}
+	return callsiteFor(pcs[0])
+}
+
+// callsiteFor returns the callsite for a program counter returned from
+// runtime.Callers.
+func callsiteFor(pc uintptr) *callsite {
+	if cs, ok := callsites.Load(pc); ok {
+		return cs.(*callsite)
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	cs := newCallsite(frame)
+	if cs == nil {
+		return nil
+	}
+	actual, _ := callsites.LoadOrStore(pc, cs)
+	return actual.(*callsite)
+}
+
+// newCallsite returns a new, uncached callsite for a frame, or nil if the frame
+// is unknown.
+func newCallsite(frame runtime.Frame) *callsite {
+	if frame.Function == "" && frame.File == "" {
+		return nil
+	}
+	cs := &callsite{
+		fullFile: frame.File,
+		file:     path.Base(frame.File),
+		line:     frame.Line,
+		fullFnc:  frame.Function,
+		fnc:      frame.Function,
+	}
+	if dot := strings.LastIndex(cs.fullFnc, "."); dot != -1 {
+		cs.fnc = cs.fullFnc[dot+1:]
+	}
+
+	// Full function name looks like path/to/pkg.Func, or path/to/pkg.(*T).Func.
+	// The package ends at the first dot after the last slash.
+	slash := strings.LastIndex(cs.fullFnc, "/")
+	if dot := strings.Index(cs.fullFnc[slash+1:], "."); dot != -1 {
+		cs.longPkg = cs.fullFnc[:slash+1+dot]
+		cs.shortPkg = cs.longPkg[slash+1:]
+	}
+	return cs
+}
+
+// caller returns the file name (without path), line, and function name
+// (witchout path) of the caller.
+//
+// Jumps back `skip` frames (0 = caller of `caller`).
+func caller(skip int) (file string, line int, fnc string, ok bool) {
+	cs := callsiteAt(skip + 1)
+	if cs == nil {
+		return
+	}
+	return cs.file, cs.line, cs.fnc, true
+}
+
+// fullCaller returns the full file name, line, and full function name of the
+// caller.
+//
+// Jumps back `skip` frames (0 = caller of `fullCaller`).
+func fullCaller(skip int) (file string, line int, fnc string, ok bool) {
+	cs := callsiteAt(skip + 1)
+	if cs == nil {
+		return
+	}
+	return cs.fullFile, cs.line, cs.fullFnc, true
+}
+
+// packageName returns both the long and short names of the package of the
+// caller.
+//
+// skip = 0 is the caller of `packageName`.
+func packageName(skip int) (long, short string, ok bool) {
+	cs := callsiteAt(skip + 1)
+	if cs == nil || cs.longPkg == "" {
+		return
+	}
+	return cs.longPkg, cs.shortPkg, true
+}
@@ -0,0 +1,45 @@
+package ln
+
+import "fmt"
+
+// Check logs a message to Fatal, which terminates the program, unless `cond`
+// is true. The message is formatted like Printf, after "Check failed: ", and
+// attributed to the caller of Check.
+//
+//	ln.Check(n <= len(buf), "read %d bytes into a %d byte buffer", n, len(buf))
+//
+// Replaces the `if !cond { ln.Fatal.Printf(...) }` pattern, so failed checks
+// all look the same in the logs.
+func Check(cond bool, format string, a ...any) {
+	if !cond {
+		checkFailed("Check failed: " + fmt.Sprintf(format, a...))
+	}
+}
+
+// CheckEq logs a message to Fatal, which terminates the program, unless a == b.
+// The message includes both values, like:
+//
+//	CheckEq failed: 3 != 4
+func CheckEq[T comparable](a, b T) {
+	if a != b {
+		checkFailed(fmt.Sprintf("CheckEq failed: %#v != %#v", a, b))
+	}
+}
+
+// CheckNoErr logs a message to Fatal, which terminates the program, unless
+// `err` is nil. The message includes the error, like:
+//
+//	CheckNoErr failed: open config.json: no such file or directory
+func CheckNoErr(err error) {
+	if err != nil {
+		checkFailed("CheckNoErr failed: " + err.Error())
+	}
+}
+
+// checkFailed logs `msg` to Fatal, attributed to the caller of the caller of
+// checkFailed.
+func checkFailed(msg string) {
+	if lg := Fatal.getLogger(); lg != nil {
+		lg.outputAt(callsiteAt(2), msg)
+	}
+}
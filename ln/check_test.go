@@ -0,0 +1,74 @@
+package ln
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fatalSink points Fatal at a sink that counts triggers instead of terminating,
+// until the end of the test.
+func fatalSink(t *testing.T) *sink {
+	snap := Snapshot()
+	t.Cleanup(snap.Restore)
+	s := newSink()
+	Fatal.LogTo(s)
+	Fatal.SetTrigger(s.trigger)
+	return s
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name  string
+		check func()
+		want  string // Empty if the check passes.
+	}{
+		{"Check", func() { Check(1 < 2, "math is broken") }, ""},
+		{"Check failed", func() { Check(len("ab") > 3, "want more than %d bytes", 3) }, "Check failed: want more than 3 bytes"},
+		{"CheckEq", func() { CheckEq(2, 2) }, ""},
+		{"CheckEq failed", func() { CheckEq(3, 4) }, "CheckEq failed: 3 != 4"},
+		{"CheckEq strings", func() { CheckEq("a", "b") }, `CheckEq failed: "a" != "b"`},
+		{"CheckNoErr", func() { CheckNoErr(nil) }, ""},
+		{"CheckNoErr failed", func() { CheckNoErr(errors.New("disk full")) }, "CheckNoErr failed: disk full"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := fatalSink(t)
+			tc.check()
+			if tc.want == "" {
+				if s.triggers != 0 || s.String() != "" {
+					t.Errorf("got %d triggers, message %q, want none", s.triggers, s.String())
+				}
+				return
+			}
+			if s.triggers != 1 {
+				t.Errorf("got %d triggers want 1", s.triggers)
+			}
+			got := s.String()
+			if !strings.HasPrefix(got, "F") || !strings.HasSuffix(got, " "+tc.want+"\n") {
+				t.Errorf("got %q want a Fatal message ending %q", got, tc.want)
+			}
+			if !strings.Contains(got, "(check_test.go:") {
+				t.Errorf("got %q want it attributed to the caller of the check", got)
+			}
+		})
+	}
+}
+
+func TestDCheck(t *testing.T) {
+	s := fatalSink(t)
+	DCheck(false, "debug only")
+	DCheckEq(1, 2)
+	DCheckNoErr(errors.New("debug only"))
+
+	want := 0
+	if DCheckEnabled {
+		want = 3
+	}
+	if s.triggers != want {
+		t.Errorf("got %d triggers want %d with DCheckEnabled = %v", s.triggers, want, DCheckEnabled)
+	}
+	if DCheckEnabled && !strings.Contains(s.String(), "(check_test.go:") {
+		t.Errorf("got %q want it attributed to the caller of the check", s.String())
+	}
+}
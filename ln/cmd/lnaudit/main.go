@@ -0,0 +1,126 @@
+// Command lnaudit checks audit logs written by ln.AuditWriter, and reports the
+// first broken link in each.
+//
+// Usage:
+//
+//	lnaudit -hmac-key-file=key audit.log...
+//	lnaudit -ed25519-public-key=<hex> audit.log...
+//
+// Without a key, only the hash chain is checked, not the checkpoint
+// signatures. The chain alone can be recomputed by anybody who can edit the
+// log, so logs checked without a key are reported as UNVERIFIED.
+//
+// With a key, a log with records after its last checkpoint, or with no
+// checkpoints, fails, since those records could have been changed without a
+// trace. Pass -allow-unsigned to accept them, as for a log still being written.
+//
+// A log cut short at a checkpoint still verifies, as nothing in it says how
+// long it should be. To catch that, note down the number of records covered by
+// the last checkpoint (records, less any after the last checkpoint) somewhere
+// safe, and pass it back later with -min-records.
+//
+// Exits with status 1 if any log fails.
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hegh/basics/ln"
+)
+
+var (
+	hmacKeyFile    = flag.String("hmac-key-file", "", "File holding the HMAC key the checkpoints were signed with.")
+	ed25519Key     = flag.String("ed25519-public-key", "", "Hex-encoded Ed25519 public key to check the checkpoints with.")
+	allowUnsigned  = flag.Bool("allow-unsigned", false, "Accept logs with records after the last checkpoint, or without checkpoints.")
+	minRecords     = flag.Int("min-records", 0, "Fail logs whose last checkpoint covers fewer records, like a count noted down from an earlier check.")
+	minCheckpoints = flag.Int("min-checkpoints", 0, "Fail logs with fewer checkpoints.")
+)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] audit.log...\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	v, err := verifier()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(2)
+	}
+
+	ok := true
+	for _, name := range flag.Args() {
+		if !check(name, v) {
+			ok = false
+		}
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// verifier returns the AuditVerifier selected by the flags, or nil.
+func verifier() (ln.AuditVerifier, error) {
+	switch {
+	case *hmacKeyFile != "" && *ed25519Key != "":
+		return nil, fmt.Errorf("-hmac-key-file and -ed25519-public-key are mutually exclusive")
+	case *hmacKeyFile != "":
+		key, err := os.ReadFile(*hmacKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading HMAC key: %w", err)
+		}
+		return ln.HMACKey(key), nil
+	case *ed25519Key != "":
+		key, err := hex.DecodeString(strings.TrimSpace(*ed25519Key))
+		if err != nil {
+			return nil, fmt.Errorf("decoding Ed25519 public key: %w", err)
+		}
+		return ln.Ed25519Verifier(key), nil
+	}
+	return nil, nil
+}
+
+// check verifies one log, reports the result, and returns whether it passed.
+func check(name string, v ln.AuditVerifier) bool {
+	f, err := os.Open(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return false
+	}
+	defer f.Close()
+
+	res, err := ln.VerifyAuditWith(f, v, ln.AuditExpect{MinRecords: *minRecords, MinCheckpoints: *minCheckpoints})
+	switch {
+	case errors.Is(err, ln.ErrAuditTruncated):
+		fmt.Printf("%s: TRUNCATED: %v\n", name, err)
+		return false
+	case errors.Is(err, ln.ErrAuditUnsigned) && !*allowUnsigned:
+		fmt.Printf("%s: UNSIGNED: %v\n", name, err)
+		return false
+	case errors.Is(err, ln.ErrAuditUnsigned):
+	case err != nil:
+		fmt.Printf("%s: BROKEN: %v\n", name, err)
+		return false
+	}
+
+	status := "OK"
+	if v == nil {
+		status = "UNVERIFIED"
+	}
+	fmt.Printf("%s: %s: %d records, %d checkpoints", name, status, res.Records, res.Checkpoints)
+	if res.Unsigned > 0 {
+		fmt.Printf(", %d records after the last checkpoint", res.Unsigned)
+	}
+	fmt.Println()
+	return true
+}
@@ -0,0 +1,21 @@
+//go:build !lndcheck
+
+package ln
+
+// DCheckEnabled is true if the DCheck functions are turned on, by building with
+// `-tags lndcheck`.
+const DCheckEnabled = false
+
+// DCheck is like Check, but only when built with `-tags lndcheck`. Otherwise it
+// does nothing.
+//
+// The arguments are still evaluated; guard expensive ones with DCheckEnabled.
+func DCheck(cond bool, format string, a ...any) {}
+
+// DCheckEq is like CheckEq, but only when built with `-tags lndcheck`.
+// Otherwise it does nothing.
+func DCheckEq[T comparable](a, b T) {}
+
+// DCheckNoErr is like CheckNoErr, but only when built with `-tags lndcheck`.
+// Otherwise it does nothing.
+func DCheckNoErr(err error) {}
@@ -0,0 +1,35 @@
+//go:build lndcheck
+
+package ln
+
+import "fmt"
+
+// DCheckEnabled is true if the DCheck functions are turned on, by building with
+// `-tags lndcheck`.
+const DCheckEnabled = true
+
+// DCheck is like Check, but only when built with `-tags lndcheck`. Otherwise it
+// does nothing.
+//
+// The arguments are still evaluated; guard expensive ones with DCheckEnabled.
+func DCheck(cond bool, format string, a ...any) {
+	if !cond {
+		checkFailed("Check failed: " + fmt.Sprintf(format, a...))
+	}
+}
+
+// DCheckEq is like CheckEq, but only when built with `-tags lndcheck`.
+// Otherwise it does nothing.
+func DCheckEq[T comparable](a, b T) {
+	if a != b {
+		checkFailed(fmt.Sprintf("CheckEq failed: %#v != %#v", a, b))
+	}
+}
+
+// DCheckNoErr is like CheckNoErr, but only when built with `-tags lndcheck`.
+// Otherwise it does nothing.
+func DCheckNoErr(err error) {
+	if err != nil {
+		checkFailed("CheckNoErr failed: " + err.Error())
+	}
+}
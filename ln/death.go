@@ -22,13 +22,15 @@ func AbortMe() error {
 
 // Terminate is the default trigger attached to the Fatal logger.
 //
-// It first tries to send SIGABRT to this process using AbortMe. If that
-// fails, or if the process does not die after 1 second, then it forces
+// It first runs the hooks registered with OnFatal, and flushes all sinks (see
+// RunFatalHooks). Then it tries to send SIGABRT to this process using AbortMe.
+// If that fails, or if the process does not die after 1 second, then it forces
 // termination with os.Exit(1).
 //
 // This function will not return.
 func Terminate() {
 	defer os.Exit(1)
+	RunFatalHooks()
 	if err := AbortMe(); err != nil {
 		Error.Printf("AbortMe: failed: %v", err)
 		return
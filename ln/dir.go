@@ -0,0 +1,295 @@
+package ln
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxSize is the size at which a DirWriter starts a new file, if
+// DirOptions.MaxSize is not set. This is the same as glog's default.
+const DefaultMaxSize = 1800 << 20 // 1800 MiB
+
+// maxDirFileSeq limits the sequence numbers tried for a file name that is
+// already taken, in case the error is not really about the name.
+const maxDirFileSeq = 1000
+
+// severity is one of the glog severities, each of which has its own file.
+type severity int
+
+const (
+	sevInfo severity = iota
+	sevWarning
+	sevError
+	sevFatal
+	numSeverities
+)
+
+var severityNames = [numSeverities]string{"INFO", "WARNING", "ERROR", "FATAL"}
+
+// severityOf maps a Level onto the glog severity whose file it belongs in.
+// Levels below Warning, including Trace and Debug, go in the INFO file.
+func severityOf(level Level) severity {
+	switch {
+	case level >= LevelFatal:
+		return sevFatal
+	case level >= LevelError:
+		return sevError
+	case level >= LevelWarning:
+		return sevWarning
+	default:
+		return sevInfo
+	}
+}
+
+// DirOptions controls the behavior of a DirWriter.
+//
+// Zero values select the defaults.
+type DirOptions struct {
+	// Program is the program name used in file names. Defaults to the base name
+	// of os.Args[0].
+	Program string
+
+	// MaxSize is the size, in bytes, at which a new file is started. Defaults to
+	// DefaultMaxSize.
+	MaxSize int64
+}
+
+// DirWriter is a LevelWriter that writes log files into a directory, using the
+// same layout as glog's `-log_dir` flag.
+//
+// There is one file per glog severity (INFO, WARNING, ERROR, and FATAL), named
+// like:
+//
+//	prog.host.user.log.INFO.20261016-101500.1234
+//
+// Each file includes the messages for its own severity and every higher one,
+// so the INFO file holds everything. Levels below Warning go in the INFO file.
+//
+// A symlink like `prog.INFO` points at the current file for each severity.
+//
+// Files are created on the first message of their severity. A new file (with a
+// new timestamp) is started when one reaches the maximum size. If a file with
+// the same timestamp already exists, like when one fills up within a second, a
+// sequence number is added to the name, like `.1`. Each run of the program gets
+// its own files, because the process ID is part of the name, and the symlinks
+// are moved to them.
+//
+// Safe for concurrent use.
+type DirWriter struct {
+	dir     string
+	program string
+	host    string
+	prefix  string // Like prog.host.user.log.
+	pid     int
+	maxSize int64
+	now     func() time.Time // Replaceable for testing.
+
+	lock  sync.Mutex
+	files [numSeverities]*dirFile
+}
+
+// dirFile is an open log file and the number of bytes written to it.
+type dirFile struct {
+	f      *os.File
+	size   int64
+	header int64 // Size of the header at the start of the file.
+}
+
+// NewDirWriter returns a DirWriter that creates log files in `dir`, creating
+// the directory if necessary.
+func NewDirWriter(dir string, opts DirOptions) (*DirWriter, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating log directory: %w", err)
+	}
+
+	if opts.Program == "" {
+		opts.Program = filepath.Base(os.Args[0])
+	}
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = DefaultMaxSize
+	}
+
+	host := "unknownhost"
+	if h, err := os.Hostname(); err == nil {
+		host, _, _ = strings.Cut(h, ".")
+	}
+	userName := "unknownuser"
+	if u, err := user.Current(); err == nil {
+		// Windows user names look like DOMAIN\user.
+		userName = strings.ReplaceAll(u.Username, `\`, "_")
+	}
+
+	return &DirWriter{
+		dir:     dir,
+		program: opts.Program,
+		host:    host,
+		prefix:  fmt.Sprintf("%s.%s.%s.log.", opts.Program, host, userName),
+		pid:     os.Getpid(),
+		maxSize: opts.MaxSize,
+		now:     time.Now,
+	}, nil
+}
+
+// LogToStderr makes LogToDir leave the loggers alone, so they keep writing to
+// stderr, like glog's `-logtostderr` flag.
+var LogToStderr = false
+
+// LogToDir sets up all loggers to write to a new DirWriter in `dir`, plus
+// Error and above to stderr (like glog's default `-stderrthreshold`).
+//
+// If LogToStderr is set, the loggers are not changed, and the returned
+// DirWriter is not used, so it never creates any files.
+//
+// Returns the DirWriter, which should be closed when the program exits.
+func LogToDir(dir string, opts DirOptions) (*DirWriter, error) {
+	w, err := NewDirWriter(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !LogToStderr {
+		LogAllTo(w, Threshold(LevelError, NewSyncWriter(os.Stderr)))
+	}
+	return w, nil
+}
+
+// Write writes `p` to the INFO file, as its level is unknown.
+func (w *DirWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(LevelInfo, p)
+}
+
+// WriteLevel writes `p` to the file for the severity of `level`, and the files
+// for all lower severities.
+func (w *DirWriter) WriteLevel(level Level, p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	for sev := severityOf(level); sev >= sevInfo; sev-- {
+		df, err := w.file(sev, int64(len(p)))
+		if err != nil {
+			return 0, err
+		}
+		n, err := df.f.Write(p)
+		df.size += int64(n)
+		if err != nil {
+			return n, err
+		}
+	}
+	return len(p), nil
+}
+
+// Sync commits the contents of every open file to stable storage.
+func (w *DirWriter) Sync() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var err error
+	for _, df := range w.files {
+		if df == nil {
+			continue
+		}
+		if serr := df.f.Sync(); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
+
+// Close closes every open file. Writing after Close reopens them, as new
+// files.
+func (w *DirWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	var err error
+	for sev, df := range w.files {
+		if df == nil {
+			continue
+		}
+		if cerr := df.f.Close(); err == nil {
+			err = cerr
+		}
+		w.files[sev] = nil
+	}
+	return err
+}
+
+// file returns the file to write `n` bytes of severity `sev` to, creating or
+// rotating it if necessary.
+//
+// Must be called with the lock held.
+func (w *DirWriter) file(sev severity, n int64) (*dirFile, error) {
+	df := w.files[sev]
+
+	// A message too big for any file goes in a new file by itself, instead of
+	// starting a new file for every write.
+	if df != nil && (df.size+n <= w.maxSize || df.size == df.header) {
+		return df, nil
+	}
+
+	if df != nil {
+		df.f.Close()
+		w.files[sev] = nil
+	}
+	df, err := w.create(sev)
+	if err != nil {
+		return nil, err
+	}
+	w.files[sev] = df
+	return df, nil
+}
+
+// create creates a new file for the given severity, writes its header, and
+// points the severity's symlink at it.
+func (w *DirWriter) create(sev severity) (*dirFile, error) {
+	now := w.now()
+	base := fmt.Sprintf("%s%s.%s.%d", w.prefix, severityNames[sev],
+		now.Format("20060102-150405"), w.pid)
+	name := base
+	var f *os.File
+	for seq := 1; ; seq++ {
+		var err error
+		f, err = os.OpenFile(filepath.Join(w.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL|os.O_APPEND, 0o644)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, fs.ErrExist) || seq > maxDirFileSeq {
+			return nil, fmt.Errorf("creating log file: %w", err)
+		}
+		// Started within the same second as the last file.
+		name = fmt.Sprintf("%s.%d", base, seq)
+	}
+
+	// Point the symlink at the new file. Build it under a temporary name and
+	// rename it into place, so there is always a symlink. Failure is not fatal;
+	// glog ignores it too.
+	link := filepath.Join(w.dir, w.program+"."+severityNames[sev])
+	tmp := fmt.Sprintf("%s.%d.tmp", link, w.pid)
+	os.Remove(tmp)
+	if err := os.Symlink(name, tmp); err == nil {
+		if err := os.Rename(tmp, link); err != nil {
+			os.Remove(tmp)
+		}
+	}
+
+	header := fmt.Sprintf("Log file created at: %s\n"+
+		"Running on machine: %s\n"+
+		"Binary: Built with %s %s for %s/%s\n"+
+		"Log line format: [TDIWEF]mmdd hh:mm:ss.uuuuuu func(file:line) msg\n",
+		now.Format("2006/01/02 15:04:05"),
+		w.host,
+		runtime.Compiler, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	n, err := f.WriteString(header)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("writing log file header: %w", err)
+	}
+	return &dirFile{f: f, size: int64(n), header: int64(n)}, nil
+}
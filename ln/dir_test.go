@@ -0,0 +1,154 @@
+package ln
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readLog returns the contents of the file the symlink for `sev` points at, and
+// the name of that file.
+func readLog(t *testing.T, dir, sev string) (name, contents string) {
+	t.Helper()
+	name, err := os.Readlink(filepath.Join(dir, "prog."+sev))
+	if err != nil {
+		t.Fatalf("reading %s symlink: %v", sev, err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("reading %s log: %v", sev, err)
+	}
+	return name, string(data)
+}
+
+// TestDirWriterLayout verifies file names, symlinks, and which messages go in
+// which file.
+func TestDirWriterLayout(t *testing.T) {
+	defer Snapshot().Restore()
+
+	dir := filepath.Join(t.TempDir(), "logs")
+	w, err := LogToDir(dir, DirOptions{Program: "prog"})
+	if err != nil {
+		t.Fatalf("LogToDir: %v", err)
+	}
+	defer w.Close()
+	Error.LogTo(w) // Keep test output off stderr.
+
+	Debug("debug message")
+	Info("info message")
+	Warning("warning message")
+	Error("error message")
+
+	nameRE := regexp.MustCompile(`^prog\.[^.]+\.[^.]+\.log\.(INFO|WARNING|ERROR)\.\d{8}-\d{6}\.\d+$`)
+	for _, tc := range []struct {
+		sev     string
+		want    []string
+		notWant []string
+	}{
+		{"INFO", []string{"debug message", "info message", "warning message", "error message"}, nil},
+		{"WARNING", []string{"warning message", "error message"}, []string{"info message"}},
+		{"ERROR", []string{"error message"}, []string{"warning message"}},
+	} {
+		name, contents := readLog(t, dir, tc.sev)
+		if m := nameRE.FindStringSubmatch(name); m == nil || m[1] != tc.sev {
+			t.Errorf("got %q which is not a glog-style %s file name", name, tc.sev)
+		}
+		if !strings.HasPrefix(contents, "Log file created at: ") {
+			t.Errorf("got %q want a glog-style header in %s file", contents, tc.sev)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(contents, want) {
+				t.Errorf("got %q want %q in %s file", contents, want, tc.sev)
+			}
+		}
+		for _, notWant := range tc.notWant {
+			if strings.Contains(contents, notWant) {
+				t.Errorf("got %q which contains %q in %s file", contents, notWant, tc.sev)
+			}
+		}
+	}
+
+	if _, err := os.Lstat(filepath.Join(dir, "prog.FATAL")); !os.IsNotExist(err) {
+		t.Errorf("got %v want not-exist for FATAL symlink with no fatal messages", err)
+	}
+}
+
+// TestDirWriterRotate verifies a new file is started when one fills up, and
+// the symlink follows it.
+func TestDirWriterRotate(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewDirWriter(dir, DirOptions{Program: "prog", MaxSize: 300})
+	if err != nil {
+		t.Fatalf("NewDirWriter: %v", err)
+	}
+	defer w.Close()
+	now := time.Date(2026, 10, 16, 10, 15, 0, 0, time.Local)
+	w.now = func() time.Time { return now }
+
+	l := NewLevel(LevelInfo, w, nil)
+	l("first message")
+	first, _ := readLog(t, dir, "INFO")
+	if !strings.Contains(first, ".INFO.20261016-101500.") {
+		t.Errorf("got %q want a name with the injected time", first)
+	}
+
+	now = now.Add(time.Second)
+	l(strings.Repeat("x", 200)) // Does not fit after the header and first message.
+	second, contents := readLog(t, dir, "INFO")
+	if second == first {
+		t.Fatalf("got %q for both files want a new file after filling the first", first)
+	}
+	if strings.Contains(contents, "first message") {
+		t.Errorf("got %q want only the second message in the new file", contents)
+	}
+
+	// The old file is left alone.
+	old, err := os.ReadFile(filepath.Join(dir, first))
+	if err != nil {
+		t.Fatalf("reading old log: %v", err)
+	}
+	if !strings.Contains(string(old), "first message") {
+		t.Errorf("got %q want the first message in the old file", old)
+	}
+}
+
+// TestDirWriterRotateSameSecond verifies files started within the same second
+// get their own names, instead of reopening the last file.
+func TestDirWriterRotateSameSecond(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewDirWriter(dir, DirOptions{Program: "prog", MaxSize: 300})
+	if err != nil {
+		t.Fatalf("NewDirWriter: %v", err)
+	}
+	defer w.Close()
+	now := time.Date(2026, 10, 16, 10, 15, 0, 0, time.Local)
+	w.now = func() time.Time { return now }
+
+	l := NewLevel(LevelInfo, w, nil)
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		l(strings.Repeat("x", 200)) // Does not fit after another message.
+		name, contents := readLog(t, dir, "INFO")
+		if seen[name] {
+			t.Errorf("got %q again want a new file for message %d", name, i)
+		}
+		seen[name] = true
+		if got, want := strings.Count(contents, "Log file created at: "), 1; got != want {
+			t.Errorf("got %d want %d headers in %s", got, want, name)
+		}
+		if got, want := strings.Count(contents, "xxx\n"), 1; got != want {
+			t.Errorf("got %d want %d messages in %s", got, want, name)
+		}
+	}
+	if !seen[filepath.Base(w.files[sevInfo].f.Name())] {
+		t.Errorf("got %q which is not one of the files the symlink pointed at", w.files[sevInfo].f.Name())
+	}
+	for name := range seen {
+		if !strings.Contains(name, ".INFO.20261016-101500.") {
+			t.Errorf("got %q want a name with the injected time", name)
+		}
+	}
+}
@@ -6,8 +6,8 @@
 //	I1203 10:04:59.846813 FuncName(filename.go:65) Message
 //
 // Components:
-//   - I: The logging level (Info in this case). Other built-in values are D for
-//     Debug, W for Warning, E for Error, and F for Fatal.
+//   - I: The logging level (Info in this case). Other built-in values are T for
+//     Trace, D for Debug, W for Warning, E for Error, and F for Fatal.
 //   - 1203: The date, MMDD (December 3rd).
 //   - 10:04:59.846813: Timestamp, hh:mm:ss.micros
 //   - FuncName: The name of the function that logged the message.
@@ -31,11 +31,21 @@
 //	ln.Error.Printf("error %s", "message")
 //	ln.Fatal.Printf("fatal %s", "message")
 //
+// Building expensive messages only when they will be logged:
+//
+//	ln.V(3).Func(func() string { return expensiveDump(state) })
+//
 // Setting the verbosity:
 //
 //	ln.Verbosity = 5
 //	ln.PackageVerbosity["main"] = 2
 //	delete(ln.PackageVerbosity, "test")
+//	ln.SetVModule("server=2,handler_*=3") // Per source file.
+//
+// Setting the verbosity from the environment, before any `init` function
+// runs (see EnvVerbosity and friends):
+//
+//	LN_V=2 LN_VMODULE=server=3 LN_TZ=UTC ./program
 //
 // Setting the verbosity with a flag:
 //
@@ -55,6 +65,18 @@
 //   - `ln.Warning("msg")` goes to `warningFile` and `infoFile`, and
 //   - `ln.Info("msg")` and `ln.V(0).Print("msg")` go to `infoFile`.
 //
+// Levels are ordered, so sinks can be limited to a minimum level:
+//
+//	ln.LogAllTo(logFile, ln.Threshold(ln.LevelWarning, os.Stderr))
+//
+// Now everything goes to `logFile`, but only Warning and above go to `stderr`.
+//
+// Custom levels can be registered between the built-in ones:
+//
+//	const LevelNotice = ln.LevelInfo + 5
+//	ln.RegisterLevel(LevelNotice, "notice", "N")
+//	LevelNotice.Logger()("notice message")
+//
 // Setting up output to go through a testing.T:
 //
 //	ln.Info = ln.MakeLogger("I", ln.PrintWriter{t.Log}, nil)
@@ -0,0 +1,337 @@
+package ln
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// RecordCallsites turns on the callsite registry, like the Linux kernel's
+// dynamic debug: every V and Debug callsite is recorded the first time it runs,
+// and can be listed with Callsites.
+//
+// Off by default, because V has to look up its caller on every call to record
+// it, which makes V several times slower when there are no package or file
+// verbosity overrides. Set it early, before the callsites of interest run, for
+// Callsites to be able to list them. Setting a callsite's state with
+// SetCallsite turns on recording too, while any state is set.
+var RecordCallsites = false
+
+// CallsiteState controls whether a V or Debug callsite logs, regardless of the
+// verbosity settings.
+type CallsiteState int
+
+const (
+	// CallsiteDefault leaves the decision to the verbosity settings (for V) or
+	// the Debug logger.
+	CallsiteDefault CallsiteState = iota
+
+	// CallsiteEnabled makes V return the Info logger at matching callsites,
+	// whatever the verbosity. Has no effect on Debug, which logs anyway.
+	CallsiteEnabled
+
+	// CallsiteDisabled makes V return the nil logger, and Debug discard its
+	// messages, at matching callsites.
+	CallsiteDisabled
+)
+
+// String returns "default", "enabled", or "disabled".
+func (s CallsiteState) String() string {
+	switch s {
+	case CallsiteDefault:
+		return "default"
+	case CallsiteEnabled:
+		return "enabled"
+	case CallsiteDisabled:
+		return "disabled"
+	}
+	return "CallsiteState(" + strconv.Itoa(int(s)) + ")"
+}
+
+// callsiteKind is the kind of logging done at a recorded callsite.
+type callsiteKind int32
+
+const (
+	callsiteUnrecorded callsiteKind = iota
+	callsiteV
+	callsiteDebug
+)
+
+// CallsiteInfo describes a callsite in the registry.
+type CallsiteInfo struct {
+	File     string // Full path.
+	Line     int
+	Function string // Full name, like path/to/pkg.Func.
+
+	// Kind is "V" or "Debug".
+	Kind string
+
+	// V is the verbosity level of the most recent call to V, for V callsites.
+	// If the call was inlined in more than one place, the highest level.
+	V int
+
+	// State is the state set by the most recent matching SetCallsite call.
+	State CallsiteState
+}
+
+// String formats the callsite like "V(2) path/to/file.go:12 pkg.Func [enabled]".
+func (c CallsiteInfo) String() string {
+	kind := c.Kind
+	if kind == "V" {
+		kind = "V(" + strconv.Itoa(c.V) + ")"
+	}
+	return fmt.Sprintf("%s %s:%d %s [%s]", kind, c.File, c.Line, c.Function, c.State)
+}
+
+// callsiteRule sets the state of callsites matching a pattern.
+type callsiteRule struct {
+	pattern string
+	file    string // Empty for a function pattern.
+	line    int    // 0 for the whole file.
+	state   CallsiteState
+}
+
+// callsiteRules is an immutable set of rules built by SetCallsite.
+type callsiteRules struct {
+	rules []callsiteRule // Later rules take precedence.
+}
+
+// ruleMatch caches the result of matching a callsite against a set of rules.
+type ruleMatch struct {
+	rules *callsiteRules
+	state CallsiteState
+}
+
+var (
+	ruleLock sync.Mutex // Serializes changes to callsiteRules.
+
+	// rules holds the current *callsiteRules, or nil if there are none.
+	rules atomic.Pointer[callsiteRules]
+)
+
+// dynamicCallsites returns true if V and Debug callsites need to be recorded
+// and checked against the rules.
+func dynamicCallsites() bool {
+	return RecordCallsites || rules.Load() != nil
+}
+
+// SetCallsite sets the state of the V and Debug callsites matching `pattern`,
+// including those that have not run yet.
+//
+// The pattern is one of:
+//   - "file.go:line", for a single line
+//   - "file.go", for a whole file
+//   - a function name, like "Func", "pkg.Func", or "path/to/pkg.(*T).Method"
+//
+// File names match the base name, or the end of the full path, like
+// "pkg/file.go". If more than one pattern matches a callsite, the most recently
+// set one wins. Setting a pattern to CallsiteDefault removes it.
+//
+// For example, to log everything from one V call no matter the verbosity:
+//
+//	ln.SetCallsite("server.go:123", ln.CallsiteEnabled)
+func SetCallsite(pattern string, state CallsiteState) error {
+	r := callsiteRule{pattern: pattern, state: state}
+	if file, line, ok := strings.Cut(pattern, ":"); ok {
+		n, err := strconv.Atoi(line)
+		if err != nil || n <= 0 || file == "" {
+			return fmt.Errorf("callsite pattern '%s' not in 'file.go:line' format", pattern)
+		}
+		r.file, r.line = file, n
+	} else if strings.HasSuffix(pattern, ".go") {
+		r.file = pattern
+	} else if pattern == "" {
+		return fmt.Errorf("empty callsite pattern")
+	}
+
+	ruleLock.Lock()
+	defer ruleLock.Unlock()
+	var rs []callsiteRule
+	if old := rules.Load(); old != nil {
+		for _, o := range old.rules {
+			if o.pattern != pattern {
+				rs = append(rs, o)
+			}
+		}
+	}
+	if state != CallsiteDefault {
+		rs = append(rs, r)
+	}
+
+	if len(rs) == 0 {
+		rules.Store(nil)
+	} else {
+		rules.Store(&callsiteRules{rules: rs})
+	}
+	return nil
+}
+
+// ResetCallsites removes every state set by SetCallsite, and forgets the
+// recorded callsites, so Callsites only lists those that run again.
+func ResetCallsites() {
+	ruleLock.Lock()
+	defer ruleLock.Unlock()
+	rules.Store(nil)
+	callsites.Range(func(_, v any) bool {
+		cs := v.(*callsite)
+		cs.kind.Store(int32(callsiteUnrecorded))
+		cs.vlevel.Store(0)
+		return true
+	})
+}
+
+// Callsites returns every recorded V and Debug callsite, sorted by file and
+// line.
+//
+// Callsites are only recorded while RecordCallsites is true, or while a state
+// is set with SetCallsite, so a program that wants to list its callsites has
+// to set RecordCallsites before they run.
+func Callsites() []CallsiteInfo {
+	seen := make(map[CallsiteInfo]int) // Index in infos, ignoring V.
+	var infos []CallsiteInfo
+	callsites.Range(func(_, v any) bool {
+		cs := v.(*callsite)
+		kind := callsiteKind(cs.kind.Load())
+		if kind == callsiteUnrecorded {
+			return true
+		}
+		info := CallsiteInfo{
+			File:     cs.fullFile,
+			Line:     cs.line,
+			Function: cs.fullFnc,
+			Kind:     "V",
+			State:    cs.state(),
+		}
+		if kind == callsiteDebug {
+			info.Kind = "Debug"
+		} else {
+			info.V = int(cs.vlevel.Load())
+		}
+
+		// One line may have more than one program counter, if it was inlined.
+		key := info
+		key.V = 0
+		if i, ok := seen[key]; ok {
+			infos[i].V = max(infos[i].V, info.V)
+		} else {
+			seen[key] = len(infos)
+			infos = append(infos, info)
+		}
+		return true
+	})
+
+	sort.Slice(infos, func(i, j int) bool {
+		if infos[i].File != infos[j].File {
+			return infos[i].File < infos[j].File
+		}
+		if infos[i].Line != infos[j].Line {
+			return infos[i].Line < infos[j].Line
+		}
+		return infos[i].Kind < infos[j].Kind
+	})
+	return infos
+}
+
+// ServeCallsites is an HTTP handler for operators to list and change callsites.
+//
+// GET lists the recorded callsites, one per line, like CallsiteInfo.String.
+// The list is empty unless RecordCallsites is set (see Callsites).
+//
+// POST sets the state of the callsites matching the `pattern` form value, to
+// the `state` form value ("enabled", "disabled", or "default"), like
+// SetCallsite.
+//
+//	http.HandleFunc("/debug/callsites", ln.ServeCallsites)
+//
+//	curl -d pattern=server.go:123 -d state=enabled localhost:8080/debug/callsites
+func ServeCallsites(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	case http.MethodPost:
+		var state CallsiteState
+		switch s := r.FormValue("state"); s {
+		case "enabled":
+			state = CallsiteEnabled
+		case "disabled":
+			state = CallsiteDisabled
+		case "default":
+			state = CallsiteDefault
+		default:
+			http.Error(w, fmt.Sprintf("unknown callsite state %q", s), http.StatusBadRequest)
+			return
+		}
+		if err := SetCallsite(r.FormValue("pattern"), state); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, c := range Callsites() {
+		fmt.Fprintln(w, c)
+	}
+}
+
+// record adds the callsite to the registry, if it is not already there.
+//
+// For V callsites, also records the level.
+func (cs *callsite) record(kind callsiteKind, level int) {
+	if kind == callsiteV && cs.vlevel.Load() != int32(level) {
+		cs.vlevel.Store(int32(level))
+	}
+	if callsiteKind(cs.kind.Load()) != kind {
+		cs.kind.Store(int32(kind))
+	}
+}
+
+// state returns the state of the callsite set by the current rules.
+//
+// The result is cached in the callsite until the rules change.
+func (cs *callsite) state() CallsiteState {
+	rs := rules.Load()
+	if rs == nil {
+		return CallsiteDefault
+	}
+	if m := cs.rule.Load(); m != nil && m.rules == rs {
+		return m.state
+	}
+
+	m := &ruleMatch{rules: rs}
+	for i := len(rs.rules) - 1; i >= 0; i-- {
+		if r := rs.rules[i]; r.matches(cs) {
+			m.state = r.state
+			break
+		}
+	}
+	cs.rule.Store(m)
+	return m.state
+}
+
+// matches returns true if the rule applies to the callsite.
+func (r *callsiteRule) matches(cs *callsite) bool {
+	if r.file == "" {
+		return cs.fnc == r.pattern || cs.fullFnc == r.pattern || strings.HasSuffix(cs.fullFnc, "/"+r.pattern)
+	}
+	if r.line != 0 && r.line != cs.line {
+		return false
+	}
+	return cs.file == r.file || cs.fullFile == r.file || strings.HasSuffix(cs.fullFile, "/"+r.file)
+}
+
+// debugDisabled records `cs` as a Debug callsite if the registry is on, and
+// returns true if it has been disabled.
+func debugDisabled(cs *callsite) bool {
+	if cs == nil || !dynamicCallsites() {
+		return false
+	}
+	cs.record(callsiteDebug, 0)
+	return cs.state() == CallsiteDisabled
+}
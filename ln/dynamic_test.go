@@ -0,0 +1,148 @@
+package ln
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+)
+
+// Callsites for TestCallsites, each in its own function so they can be found
+// by name.
+func dynamicV(level int) Logger     { return V(level) }
+func dynamicDebug(msg string)       { Debug.Print(msg) }
+func dynamicDebugf(msg string)      { Debug.Printf("%s", msg) }
+func dynamicEnabled(level int) bool { return LevelEnabled(level) }
+
+// findCallsite returns the recorded callsite in function `fnc`.
+func findCallsite(t *testing.T, fnc string) CallsiteInfo {
+	t.Helper()
+	for _, c := range Callsites() {
+		if strings.HasSuffix(c.Function, "."+fnc) {
+			return c
+		}
+	}
+	t.Fatalf("no callsite recorded for %s in %v", fnc, Callsites())
+	return CallsiteInfo{}
+}
+
+// resetCallsites clears the registry, and clears it again, with the package
+// settings restored, when the test is done.
+func resetCallsites(t *testing.T) {
+	ResetCallsites()
+	snap := Snapshot()
+	record := RecordCallsites
+	t.Cleanup(func() {
+		snap.Restore()
+		RecordCallsites = record
+		ResetCallsites()
+	})
+}
+
+func TestCallsites(t *testing.T) {
+	resetCallsites(t)
+	s := newSink()
+	Info.LogTo(s)
+	Debug.LogTo(s)
+	Verbosity = 0
+
+	RecordCallsites = true
+	dynamicV(7).Print("not logged")
+	dynamicDebug("debug")
+	if got := s.String(); strings.Count(got, "\n") != 1 {
+		t.Errorf("got %q want just the debug message", got)
+	}
+
+	v := findCallsite(t, "dynamicV")
+	if v.Kind != "V" || v.V != 7 || v.State != CallsiteDefault || path.Base(v.File) != "dynamic_test.go" {
+		t.Errorf("got %v want V(7) in dynamic_test.go, default state", v)
+	}
+	if d := findCallsite(t, "dynamicDebug"); d.Kind != "Debug" {
+		t.Errorf("got %v want a Debug callsite", d)
+	}
+
+	// States apply regardless of the verbosity, and from the most recent rule.
+	RecordCallsites = false
+	s.data.Reset()
+	for _, tc := range []struct {
+		pattern string
+		state   CallsiteState
+		want    bool
+	}{
+		{fmt.Sprintf("dynamic_test.go:%d", v.Line), CallsiteEnabled, true},
+		{"dynamicV", CallsiteDisabled, false},
+		{"ln.dynamicV", CallsiteEnabled, true},
+		{"ln/dynamic_test.go", CallsiteDisabled, false},
+		{"ln/dynamic_test.go", CallsiteDefault, true}, // Back to the last function rule.
+	} {
+		if err := SetCallsite(tc.pattern, tc.state); err != nil {
+			t.Fatalf("SetCallsite(%q, %v): %v", tc.pattern, tc.state, err)
+		}
+		if got := dynamicV(3).Enabled(); got != tc.want {
+			t.Errorf("got %v want %v for V(3) after SetCallsite(%q, %v)", got, tc.want, tc.pattern, tc.state)
+		}
+	}
+	if err := SetCallsite("dynamicEnabled", CallsiteEnabled); err != nil {
+		t.Fatal(err)
+	}
+	if !dynamicEnabled(5) {
+		t.Errorf("got false want true for LevelEnabled at an enabled callsite")
+	}
+
+	// Disabled Debug callsites discard their messages.
+	if err := SetCallsite("dynamicDebug", CallsiteDisabled); err != nil {
+		t.Fatal(err)
+	}
+	dynamicDebug("dropped")
+	dynamicDebugf("kept")
+	if got := s.String(); strings.Contains(got, "dropped") || !strings.Contains(got, "kept") {
+		t.Errorf("got %q want only the message from dynamicDebugf", got)
+	}
+
+	ResetCallsites()
+	if got := Callsites(); len(got) != 0 {
+		t.Errorf("got %v want no callsites after ResetCallsites", got)
+	}
+	if dynamicV(3).Enabled() {
+		t.Errorf("got enabled V(3) after ResetCallsites")
+	}
+
+	for _, pattern := range []string{"", "file.go:x", "file.go:0", ":12"} {
+		if err := SetCallsite(pattern, CallsiteEnabled); err == nil {
+			t.Errorf("SetCallsite(%q): expected error", pattern)
+		}
+	}
+}
+
+func TestServeCallsites(t *testing.T) {
+	resetCallsites(t)
+	RecordCallsites = true
+	Verbosity = 0
+	dynamicV(2)
+
+	post := func(pattern, state string) int {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(url.Values{"pattern": {pattern}, "state": {state}}.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ServeCallsites(rec, req)
+		return rec.Code
+	}
+	if code := post("dynamicV", "enabled"); code != http.StatusOK {
+		t.Errorf("got status %d want %d", code, http.StatusOK)
+	}
+	if !dynamicV(2).Enabled() {
+		t.Errorf("got disabled V(2) after enabling it over HTTP")
+	}
+	if code := post("dynamicV", "sideways"); code != http.StatusBadRequest {
+		t.Errorf("got status %d want %d for a bad state", code, http.StatusBadRequest)
+	}
+
+	rec := httptest.NewRecorder()
+	ServeCallsites(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rec.Body.String(); !strings.Contains(got, "V(2) ") || !strings.Contains(got, "ln.dynamicV [enabled]") {
+		t.Errorf("got listing\n%s\nwant the enabled dynamicV callsite", got)
+	}
+}
@@ -0,0 +1,80 @@
+package ln
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Environment variables read when the package is initialized.
+//
+// Go initializes a package before any package that imports it, so settings
+// from the environment are in place before the `init` functions of every
+// package that logs through ln.
+const (
+	// EnvVerbosity sets Verbosity, like "2".
+	EnvVerbosity = "LN_V"
+
+	// EnvVModule is passed to SetVModule, like "server=2,handler_*=3".
+	EnvVModule = "LN_VMODULE"
+
+	// EnvPackageVerbosity is passed to ParsePackageVerbosity, like
+	// "main=2,net/http=1".
+	EnvPackageVerbosity = "LN_PACKAGE_VERBOSITY"
+
+	// EnvTZ sets TZ, using time.LoadLocation, like "UTC" or
+	// "America/New_York".
+	EnvTZ = "LN_TZ"
+
+	// EnvLogToStderr sets LogToStderr, using strconv.ParseBool, like "true" or
+	// "1".
+	EnvLogToStderr = "LN_LOGTOSTDERR"
+)
+
+func init() {
+	if err := configureFromEnv(os.Getenv); err != nil {
+		Warning.Printf("ignoring bad logging configuration from the environment: %v", err)
+	}
+}
+
+// configureFromEnv applies the settings in the environment variables above,
+// read through `getenv`.
+//
+// Applies every valid setting, and returns errors for the rest, joined.
+func configureFromEnv(getenv func(string) string) error {
+	var errs []error
+	if s := getenv(EnvVerbosity); s != "" {
+		if v, err := strconv.Atoi(s); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", EnvVerbosity, err))
+		} else {
+			Verbosity = v
+		}
+	}
+	if s := getenv(EnvVModule); s != "" {
+		if err := SetVModule(s); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", EnvVModule, err))
+		}
+	}
+	if s := getenv(EnvPackageVerbosity); s != "" {
+		if err := ParsePackageVerbosity(s); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", EnvPackageVerbosity, err))
+		}
+	}
+	if s := getenv(EnvTZ); s != "" {
+		if tz, err := time.LoadLocation(s); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", EnvTZ, err))
+		} else {
+			TZ = tz
+		}
+	}
+	if s := getenv(EnvLogToStderr); s != "" {
+		if b, err := strconv.ParseBool(s); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", EnvLogToStderr, err))
+		} else {
+			LogToStderr = b
+		}
+	}
+	return errors.Join(errs...)
+}
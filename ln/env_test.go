@@ -0,0 +1,123 @@
+package ln
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestConfigureFromEnv verifies each environment variable is applied, and bad
+// values are reported without stopping the rest.
+func TestConfigureFromEnv(t *testing.T) {
+	defer Snapshot().Restore()
+	defer func(b bool) { LogToStderr = b }(LogToStderr)
+	PackageVerbosity = make(map[string]int)
+
+	env := map[string]string{
+		EnvVerbosity:        "3",
+		EnvVModule:          "env_test=5",
+		EnvPackageVerbosity: "net/http=2",
+		EnvTZ:               "UTC",
+		EnvLogToStderr:      "true",
+	}
+	if err := configureFromEnv(func(k string) string { return env[k] }); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if Verbosity != 3 {
+		t.Errorf("got %d want %d for Verbosity", Verbosity, 3)
+	}
+	if got, want := VModule(), "env_test=5"; got != want {
+		t.Errorf("got %q want %q for VModule()", got, want)
+	}
+	if got, want := PackageVerbosity["net/http"], 2; got != want {
+		t.Errorf("got %d want %d for PackageVerbosity[net/http]", got, want)
+	}
+	if TZ == nil || TZ.String() != "UTC" {
+		t.Errorf("got %v want UTC for TZ", TZ)
+	}
+	if !LogToStderr {
+		t.Errorf("got false want true for LogToStderr")
+	}
+
+	// The file override applies here.
+	if !LevelEnabled(5) || LevelEnabled(6) {
+		t.Errorf("got %v, %v want true, false for LevelEnabled(5), LevelEnabled(6)", LevelEnabled(5), LevelEnabled(6))
+	}
+
+	env = map[string]string{
+		EnvVerbosity: "lots",
+		EnvTZ:        "Nowhere/Special",
+		EnvVModule:   "env_test=1",
+	}
+	if err := configureFromEnv(func(k string) string { return env[k] }); err == nil {
+		t.Errorf("expected error for bad values")
+	}
+	if Verbosity != 3 {
+		t.Errorf("got %d want %d for Verbosity after a bad value", Verbosity, 3)
+	}
+	if got, want := VModule(), "env_test=1"; got != want {
+		t.Errorf("got %q want %q for VModule() alongside bad values", got, want)
+	}
+}
+
+// TestVModule verifies file patterns override package and global verbosity.
+func TestVModule(t *testing.T) {
+	defer Snapshot().Restore()
+	Verbosity = 0
+	PackageVerbosity = map[string]int{shortPackageName: 1}
+
+	for _, tc := range []struct {
+		spec string
+		want int
+	}{
+		{"", 1},
+		{"other=4", 1},
+		{"env_*=4", 4},
+		{"env_test.go=3", 3},
+		{"env_test=2,env_*=4", 2},
+		{"ln/env_test=5", 5},
+		{"*/env_test=6", 6},
+		{"other/env_test=4", 1},
+	} {
+		if err := SetVModule(tc.spec); err != nil {
+			t.Errorf("SetVModule(%q): unexpected error %v", tc.spec, err)
+			continue
+		}
+		if !LevelEnabled(tc.want) || LevelEnabled(tc.want+1) {
+			t.Errorf("got LevelEnabled(%d), LevelEnabled(%d) = %v, %v want true, false with vmodule %q",
+				tc.want, tc.want+1, LevelEnabled(tc.want), LevelEnabled(tc.want+1), tc.spec)
+		}
+	}
+
+	for _, spec := range []string{"env_test", "=1", "env_test=x", "[=1"} {
+		if err := SetVModule(spec); err == nil {
+			t.Errorf("SetVModule(%q): expected error", spec)
+		}
+	}
+}
+
+// TestLogToStderr verifies LogToDir leaves the loggers alone when LogToStderr
+// is set.
+func TestLogToStderr(t *testing.T) {
+	defer Snapshot().Restore()
+	defer func(b bool) { LogToStderr = b }(LogToStderr)
+	LogToStderr = true
+
+	s := newSink()
+	Info.LogTo(s)
+	dir := t.TempDir()
+	w, err := LogToDir(dir, DirOptions{Program: "prog"})
+	if err != nil {
+		t.Fatalf("LogToDir: %v", err)
+	}
+	defer w.Close()
+
+	Info("message")
+	if s.String() == "" {
+		t.Errorf("got no output want Info to keep writing to its sink")
+	}
+	if _, err := os.Lstat(filepath.Join(dir, "prog.INFO")); !os.IsNotExist(err) {
+		t.Errorf("got %v want not-exist for INFO symlink", err)
+	}
+}
@@ -0,0 +1,246 @@
+package ln
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Level is the severity of a log message. Higher levels are more severe.
+//
+// The built-in levels are spaced out so custom levels can be registered
+// between them with RegisterLevel.
+type Level int
+
+// The built-in levels.
+const (
+	LevelTrace   Level = 10
+	LevelDebug   Level = 20
+	LevelInfo    Level = 30
+	LevelWarning Level = 40
+	LevelError   Level = 50
+	LevelFatal   Level = 60
+)
+
+// levelInfo holds the registered details of a Level.
+type levelInfo struct {
+	name    string // Lower case.
+	prefix  string
+	trigger func() // Default trigger, used by LogAllTo. May be nil.
+}
+
+var (
+	levelLock sync.RWMutex
+	levels    = map[Level]*levelInfo{
+		LevelTrace:   {name: "trace", prefix: "T"},
+		LevelDebug:   {name: "debug", prefix: "D"},
+		LevelInfo:    {name: "info", prefix: "I"},
+		LevelWarning: {name: "warning", prefix: "W"},
+		LevelError:   {name: "error", prefix: "E"},
+		LevelFatal:   {name: "fatal", prefix: "F"},
+	}
+
+	// loggers maps each registered level onto the package Logger for the level.
+	//
+	// Guarded by levelLock.
+	loggers map[Level]*Logger
+)
+
+// The package loggers depend on Terminate, which depends on the registry (to
+// flush every logger's sinks), so the registry can't refer to them until the
+// package variables have been initialized.
+func init() {
+	levels[LevelFatal].trigger = Terminate
+	loggers = map[Level]*Logger{
+		LevelTrace:   &Trace,
+		LevelDebug:   &Debug,
+		LevelInfo:    &Info,
+		LevelWarning: &Warning,
+		LevelError:   &Error,
+		LevelFatal:   &Fatal,
+	}
+	defaultScope.loggers = loggers
+	defaultScope.info = &Info
+}
+
+// RegisterLevel adds a custom level, with a Logger that writes to os.Stderr.
+//
+// The `name` is used by ParseLevel and String, and is case-insensitive. The
+// `prefix` begins each line of output, like "I" for Info.
+//
+// Returns an error if the level is already registered, or if the name or
+// prefix matches the name or prefix of another level, ignoring case, as
+// ParseLevel could not tell them apart.
+func RegisterLevel(level Level, name, prefix string) error {
+	if name == "" {
+		return fmt.Errorf("level %d: empty name", level)
+	}
+	name = strings.ToLower(name)
+
+	levelLock.Lock()
+	defer levelLock.Unlock()
+	for l, info := range levels {
+		switch {
+		case l == level:
+			return fmt.Errorf("level %d already registered as %q", level, info.name)
+		case strings.EqualFold(info.name, name) || strings.EqualFold(info.prefix, name):
+			return fmt.Errorf("level name %q already registered for level %d", name, l)
+		case strings.EqualFold(info.prefix, prefix) || strings.EqualFold(info.name, prefix):
+			return fmt.Errorf("level prefix %q already registered for level %d", prefix, l)
+		}
+	}
+
+	levels[level] = &levelInfo{name: name, prefix: prefix}
+	lg := &logger{prefix: prefix, level: level}
+	lg.setSinks([]io.Writer{os.Stderr})
+	l := newLogger(lg)
+	loggers[level] = &l
+	return nil
+}
+
+// Levels returns every registered level, from least to most severe.
+func Levels() []Level {
+	levelLock.RLock()
+	defer levelLock.RUnlock()
+	ls := make([]Level, 0, len(levels))
+	for l := range levels {
+		ls = append(ls, l)
+	}
+	sort.Slice(ls, func(i, j int) bool { return ls[i] < ls[j] })
+	return ls
+}
+
+// ParseLevel returns the registered level with the given name or prefix,
+// ignoring case. For example, "warning", "WARNING", and "W" all return
+// LevelWarning.
+//
+// Also accepts the numeric value of a registered level.
+func ParseLevel(s string) (Level, error) {
+	levelLock.RLock()
+	defer levelLock.RUnlock()
+
+	// Matched like RegisterLevel checks for duplicates, so only one can match.
+	for l, info := range levels {
+		if strings.EqualFold(info.name, s) || strings.EqualFold(info.prefix, s) {
+			return l, nil
+		}
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if _, ok := levels[Level(n)]; ok {
+			return Level(n), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// String returns the name of the level, like "warning", or "Level(n)" if the
+// level is not registered.
+func (l Level) String() string {
+	if info := l.info(); info != nil {
+		return info.name
+	}
+	return "Level(" + strconv.Itoa(int(l)) + ")"
+}
+
+// Prefix returns the prefix for the level, like "W", or "?" if the level is not
+// registered.
+func (l Level) Prefix() string {
+	if info := l.info(); info != nil {
+		return info.prefix
+	}
+	return "?"
+}
+
+// Logger returns the package Logger for the level, like Warning for
+// LevelWarning, or the nil logger if the level is not registered.
+func (l Level) Logger() Logger {
+	return defaultScope.Logger(l)
+}
+
+func (l Level) info() *levelInfo {
+	levelLock.RLock()
+	defer levelLock.RUnlock()
+	return levels[l]
+}
+
+// levelInfos returns a copy of the details of every registered level.
+func levelInfos() map[Level]levelInfo {
+	levelLock.RLock()
+	defer levelLock.RUnlock()
+	infos := make(map[Level]levelInfo, len(levels))
+	for l, info := range levels {
+		infos[l] = *info
+	}
+	return infos
+}
+
+// levelForPrefix returns the registered level with the given prefix, or false.
+func levelForPrefix(prefix string) (Level, bool) {
+	levelLock.RLock()
+	defer levelLock.RUnlock()
+	for l, info := range levels {
+		if info.prefix == prefix {
+			return l, true
+		}
+	}
+	return 0, false
+}
+
+// LevelWriter is implemented by writers that want to know the level of each
+// message written to them.
+//
+// Loggers implement LevelWriter, and pass the level of the original message
+// through when one Logger writes to another. Loggers call WriteLevel instead of
+// Write on sinks that implement it.
+type LevelWriter interface {
+	io.Writer
+
+	// WriteLevel writes a message logged at `level`.
+	WriteLevel(level Level, p []byte) (n int, err error)
+}
+
+// writeLevel writes `p` to `w`, passing the level along if `w` wants it.
+func writeLevel(w io.Writer, level Level, p []byte) (int, error) {
+	if lw, ok := w.(LevelWriter); ok {
+		return lw.WriteLevel(level, p)
+	}
+	return w.Write(p)
+}
+
+// ThresholdWriter is a LevelWriter that discards messages below a minimum
+// level.
+type ThresholdWriter struct {
+	minLevel Level
+	w        io.Writer
+}
+
+// Threshold returns a writer that passes messages at or above `minLevel`
+// through to `w`, and discards the rest.
+//
+// For example, to send everything to a file, but only Warning and above to
+// stderr:
+//
+//	ln.LogAllTo(file, ln.Threshold(ln.LevelWarning, os.Stderr))
+func Threshold(minLevel Level, w io.Writer) *ThresholdWriter {
+	return &ThresholdWriter{minLevel: minLevel, w: w}
+}
+
+// Unwrap returns the writer messages are passed through to.
+func (w *ThresholdWriter) Unwrap() io.Writer { return w.w }
+
+// Write passes `p` through, as the level of the message is unknown.
+func (w *ThresholdWriter) Write(p []byte) (int, error) { return w.w.Write(p) }
+
+// WriteLevel passes `p` through if `level` is at or above the minimum.
+//
+// Discarded messages are reported as fully written.
+func (w *ThresholdWriter) WriteLevel(level Level, p []byte) (int, error) {
+	if level < w.minLevel {
+		return len(p), nil
+	}
+	return writeLevel(w.w, level, p)
+}
@@ -0,0 +1,218 @@
+package ln
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestParseLevel verifies levels can be parsed by name, prefix, or number.
+func TestParseLevel(t *testing.T) {
+	for _, tc := range []struct {
+		s    string
+		want Level
+	}{
+		{"warning", LevelWarning},
+		{"WARNING", LevelWarning},
+		{"W", LevelWarning},
+		{"w", LevelWarning},
+		{"trace", LevelTrace},
+		{"F", LevelFatal},
+		{"30", LevelInfo},
+	} {
+		got, err := ParseLevel(tc.s)
+		if err != nil {
+			t.Errorf("ParseLevel(%q): unexpected error %v", tc.s, err)
+		} else if got != tc.want {
+			t.Errorf("got %v want %v for ParseLevel(%q)", got, tc.want, tc.s)
+		}
+	}
+
+	for _, s := range []string{"", "warn", "31"} {
+		if _, err := ParseLevel(s); err == nil {
+			t.Errorf("ParseLevel(%q): expected error", s)
+		}
+	}
+}
+
+// TestLevelOrder verifies the built-in levels are ordered by severity.
+func TestLevelOrder(t *testing.T) {
+	want := []Level{LevelTrace, LevelDebug, LevelInfo, LevelWarning, LevelError, LevelFatal}
+	var got []Level
+	for _, l := range Levels() {
+		for _, w := range want {
+			if l == w {
+				got = append(got, l)
+			}
+		}
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v want %v for built-in levels", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("got %v want %v for built-in level order", got, want)
+			break
+		}
+	}
+
+	if got, want := LevelWarning.String(), "warning"; got != want {
+		t.Errorf("got %q want %q for LevelWarning.String()", got, want)
+	}
+	if got, want := LevelWarning.Prefix(), "W"; got != want {
+		t.Errorf("got %q want %q for LevelWarning.Prefix()", got, want)
+	}
+	if got, want := Level(1).String(), "Level(1)"; got != want {
+		t.Errorf("got %q want %q for unregistered level", got, want)
+	}
+}
+
+// TestLoggerLevel verifies Loggers know their level.
+func TestLoggerLevel(t *testing.T) {
+	defer Snapshot().Restore()
+
+	if got, want := Warning.Level(), LevelWarning; got != want {
+		t.Errorf("got %v want %v for Warning.Level()", got, want)
+	}
+	if got, want := New("E", nil, nil).Level(), LevelError; got != want {
+		t.Errorf("got %v want %v for New(\"E\").Level()", got, want)
+	}
+	if got, want := New("unknown", nil, nil).Level(), LevelInfo; got != want {
+		t.Errorf("got %v want %v for New(\"unknown\").Level()", got, want)
+	}
+	if got, want := LevelTrace.Logger().String(), "T"; got != want {
+		t.Errorf("got %q want %q for LevelTrace.Logger()", got, want)
+	}
+}
+
+// TestRegisterLevel verifies custom levels can be registered and used.
+func TestRegisterLevel(t *testing.T) {
+	defer Snapshot().Restore()
+
+	const notice = LevelInfo + 5
+	if err := RegisterLevel(notice, "Notice", "N"); err != nil {
+		t.Fatalf("RegisterLevel: %v", err)
+	}
+	defer func() {
+		levelLock.Lock()
+		defer levelLock.Unlock()
+		delete(levels, notice)
+		delete(loggers, notice)
+	}()
+	if err := RegisterLevel(notice, "other", "O"); err == nil {
+		t.Errorf("expected error registering a duplicate level")
+	}
+	if err := RegisterLevel(notice+1, "notice", "O"); err == nil {
+		t.Errorf("expected error registering a duplicate name")
+	}
+	if err := RegisterLevel(notice+1, "other", "I"); err == nil {
+		t.Errorf("expected error registering a duplicate prefix")
+	}
+	if err := RegisterLevel(notice+1, "other", "n"); err == nil {
+		t.Errorf("expected error registering a prefix differing only in case")
+	}
+	if err := RegisterLevel(notice+1, "w", "O"); err == nil {
+		t.Errorf("expected error registering a name matching a prefix")
+	}
+	if err := RegisterLevel(notice+1, "other", "Info"); err == nil {
+		t.Errorf("expected error registering a prefix matching a name")
+	}
+
+	for _, name := range []string{"NOTICE", "n"} {
+		if got, err := ParseLevel(name); err != nil || got != notice {
+			t.Errorf("got %v, %v want %v for ParseLevel(%q)", got, err, notice, name)
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	LogAllTo(Threshold(notice, buf))
+	Info("dropped")
+	notice.Logger()("kept")
+	Warning("also kept")
+
+	out := buf.String()
+	if strings.Contains(out, "dropped") {
+		t.Errorf("got %q which contains a message below the threshold", out)
+	}
+	if !strings.HasPrefix(out, "N") || !strings.Contains(out, "kept") {
+		t.Errorf("got %q want a notice message", out)
+	}
+	if !strings.Contains(out, "also kept") {
+		t.Errorf("got %q want a warning message", out)
+	}
+}
+
+// TestConfigLoggers verifies a Config restores the standard levels from their
+// fields, and other levels from Loggers.
+func TestConfigLoggers(t *testing.T) {
+	defer Snapshot().Restore()
+
+	const notice = LevelInfo + 5
+	if err := RegisterLevel(notice, "notice", "N"); err != nil {
+		t.Fatalf("RegisterLevel: %v", err)
+	}
+	defer func() {
+		levelLock.Lock()
+		defer levelLock.Unlock()
+		delete(levels, notice)
+		delete(loggers, notice)
+	}()
+
+	snap := Snapshot()
+	if snap.Info == nil || snap.Fatal == nil {
+		t.Errorf("got nil Info or Fatal in the snapshot")
+	}
+	if _, ok := snap.Loggers[LevelInfo]; ok {
+		t.Errorf("got Info in Loggers, want it only in its field")
+	}
+	if _, ok := snap.Loggers[notice]; !ok {
+		t.Errorf("got no notice logger in Loggers")
+	}
+
+	// A config built with just the fields, as before Loggers.
+	info := New("X", nil, nil)
+	(&Config{Info: info}).Restore()
+	if got, want := Info.String(), info.String(); got != want {
+		t.Errorf("got %q want %q for Info", got, want)
+	}
+	if Debug != nil {
+		t.Errorf("got %q want nil for Debug, unset in the config", Debug)
+	}
+	if notice.Logger() == nil {
+		t.Errorf("got nil for notice, missing from the config")
+	}
+
+	// Loggers fills in nil fields, and other levels.
+	custom := New("Y", nil, nil)
+	(&Config{Info: info, Loggers: map[Level]Logger{LevelDebug: info, notice: custom}}).Restore()
+	if got, want := Debug.String(), info.String(); got != want {
+		t.Errorf("got %q want %q for Debug from Loggers", got, want)
+	}
+	if got, want := notice.Logger().String(), custom.String(); got != want {
+		t.Errorf("got %q want %q for notice", got, want)
+	}
+}
+
+// TestThresholdThroughChain verifies a threshold sees the original level of a
+// message passed from one Logger to another.
+func TestThresholdThroughChain(t *testing.T) {
+	all := new(bytes.Buffer)
+	important := new(bytes.Buffer)
+
+	info := New("I", nil, nil)
+	info.LogTo(all, Threshold(LevelWarning, important))
+	warning := New("W", info, nil)
+	errorL := New("E", warning, nil)
+
+	info("info")
+	warning("warning")
+	errorL("error")
+
+	if got, want := strings.Count(all.String(), "\n"), 3; got != want {
+		t.Errorf("got %d want %d lines in unfiltered sink: %q", got, want, all)
+	}
+	if got := important.String(); strings.Contains(got, "info") ||
+		!strings.Contains(got, "warning") || !strings.Contains(got, "error") {
+		t.Errorf("got %q want only warning and error in filtered sink", got)
+	}
+}
@@ -0,0 +1,274 @@
+package ln
+
+import (
+	"context"
+	"errors"
+	"io"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Flusher is implemented by sinks that buffer output, and need to be told to
+// write it out.
+type Flusher interface {
+	Flush() error
+}
+
+// FatalHookTimeout limits how long Terminate waits for the OnFatal hooks and
+// the final Flush before aborting the program.
+var FatalHookTimeout = 5 * time.Second
+
+var (
+	lifecycleLock sync.Mutex
+	registered    []io.Writer // Added by RegisterSink.
+	fatalHooks    []func()    // Added by OnFatal.
+)
+
+// RegisterSink adds a sink for Flush and Shutdown to handle, in addition to
+// the sinks they find attached to the package loggers.
+//
+// Only needed for sinks that cannot be found by following the package loggers
+// through Loggers and wrappers with an `Unwrap() io.Writer` method (like
+// SyncWriter and ThresholdWriter).
+func RegisterSink(w io.Writer) {
+	lifecycleLock.Lock()
+	defer lifecycleLock.Unlock()
+	registered = append(registered, w)
+}
+
+// OnFatal registers a hook to run when Terminate is triggered by the Fatal
+// logger, before the program aborts.
+//
+// Hooks run in the order registered, followed by Flush, all within
+// FatalHookTimeout. A hook that is still running when the timeout expires
+// does not delay termination.
+func OnFatal(hook func()) {
+	lifecycleLock.Lock()
+	defer lifecycleLock.Unlock()
+	fatalHooks = append(fatalHooks, hook)
+}
+
+// RunFatalHooks runs the hooks registered with OnFatal and then Flush, waiting
+// up to FatalHookTimeout for them to finish.
+//
+// Called by Terminate. Custom Fatal triggers should call it too, if they stop
+// the program.
+func RunFatalHooks() {
+	lifecycleLock.Lock()
+	hooks := append([]func(){}, fatalHooks...)
+	lifecycleLock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for _, hook := range hooks {
+			func() {
+				defer func() { recover() }() // One bad hook should not stop the rest.
+				hook()
+			}()
+		}
+		Flush()
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(FatalHookTimeout):
+	}
+}
+
+// Flush writes out buffered output, and syncs to stable storage, for every
+// sink attached to the package loggers and every sink added by RegisterSink.
+//
+// Only the default scope is covered. Loggers from NewScope are not, as nothing
+// keeps track of them; use their scope's Flush.
+//
+// Sinks with a `Flush() error` method are flushed, and then sinks with a
+// `Sync() error` method are synced. Errors from syncing files that do not
+// support it, like terminals and pipes, are ignored.
+//
+// Each sink is flushed and synced holding the lock that writes to it hold, so
+// it is safe to Flush while other goroutines are still logging.
+//
+// Returns all of the errors encountered, joined.
+func Flush() error {
+	return defaultScope.Flush()
+}
+
+// Flush is like the package Flush, for the sinks attached to the scope's
+// loggers. For the default scope, also flushes the sinks added by
+// RegisterSink.
+func (s *Scope) Flush() error {
+	return flushSinks(s.sinks())
+}
+
+// flushSinks flushes and then syncs the sinks in `ws`, like Flush.
+func flushSinks(ws []io.Writer) error {
+	var errs []error
+	for _, w := range ws {
+		if f, ok := w.(Flusher); ok {
+			errs = append(errs, withSinkLock(w, f.Flush))
+		}
+	}
+	for _, w := range ws {
+		if s, ok := w.(interface{ Sync() error }); ok {
+			if err := withSinkLock(w, s.Sync); !errors.Is(err, syscall.EINVAL) && !errors.Is(err, syscall.ENOTSUP) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// withSinkLock calls `f`, holding the lock writes to `w` hold (see
+// sinkStateFor).
+func withSinkLock(w io.Writer, f func() error) error {
+	if st := sinkStateFor(w); st != nil {
+		st.lock.Lock()
+		defer st.lock.Unlock()
+	}
+	return f()
+}
+
+// Shutdown flushes every sink like Flush, then closes every sink with a
+// `Close() error` method, except os.Stdout and os.Stderr.
+//
+// A wrapper that can be closed (like AuditWriter) is expected to close the
+// writer it wraps, so that writer is not closed again. Like flushing, closing
+// holds the lock that writes to the sink hold.
+//
+// Returns early with the context's error if it is done first. The flushing
+// and closing carry on in the background.
+//
+// Like Flush, only covers the default scope. Loggers from NewScope need their
+// scope's Shutdown.
+//
+// Messages logged after Shutdown are likely to be lost.
+func Shutdown(ctx context.Context) error {
+	return defaultScope.Shutdown(ctx)
+}
+
+// Shutdown is like the package Shutdown, for the sinks attached to the scope's
+// loggers. For the default scope, also covers the sinks added by
+// RegisterSink.
+func (s *Scope) Shutdown(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		ws := s.sinks()
+		errs := []error{flushSinks(ws)}
+		for _, c := range outerClosers(ws) {
+			if c == io.Closer(os.Stdout) || c == io.Closer(os.Stderr) {
+				continue
+			}
+			errs = append(errs, withSinkLock(c.(io.Writer), c.Close))
+		}
+		done <- errors.Join(errs...)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// outerClosers returns the sinks in `ws` with a `Close() error` method, except
+// those wrapped by another such sink, which closes them itself.
+func outerClosers(ws []io.Writer) []io.Closer {
+	wrapped := make(map[io.Writer]bool)
+	for _, w := range ws {
+		for {
+			_, closer := w.(io.Closer)
+			u, ok := w.(interface{ Unwrap() io.Writer })
+			if !closer || !ok {
+				break
+			}
+			w = u.Unwrap()
+			if w == nil || !reflect.ValueOf(w).Comparable() {
+				break
+			}
+			wrapped[w] = true
+		}
+	}
+
+	var cs []io.Closer
+	for _, w := range ws {
+		if reflect.ValueOf(w).Comparable() && wrapped[w] {
+			continue
+		}
+		if c, ok := w.(io.Closer); ok {
+			cs = append(cs, c)
+		}
+	}
+	return cs
+}
+
+// sinks returns every sink reachable from the package loggers, and every sink
+// added by RegisterSink, each once (as far as can be told).
+func sinks() []io.Writer {
+	return defaultScope.sinks()
+}
+
+// sinks returns every sink reachable from the scope's loggers, each once (as
+// far as can be told). For the default scope, includes every sink added by
+// RegisterSink too.
+//
+// Loggers themselves are followed, but not included. Wrappers are included,
+// along with the writers they wrap.
+func (s *Scope) sinks() []io.Writer {
+	var roots []io.Writer
+	s.lock.RLock()
+	for _, level := range slices.Sorted(maps.Keys(s.loggers)) {
+		roots = append(roots, *s.loggers[level])
+	}
+	s.lock.RUnlock()
+	if s == defaultScope {
+		lifecycleLock.Lock()
+		roots = append(roots, registered...)
+		lifecycleLock.Unlock()
+	}
+
+	var ws []io.Writer
+	seenLoggers := make(map[*logger]bool)
+	seen := make(map[io.Writer]bool)
+	var walk func(w io.Writer)
+	walk = func(w io.Writer) {
+		if w == nil {
+			return
+		}
+
+		if l, ok := w.(Logger); ok {
+			lg := l.getLogger()
+			if lg == nil || seenLoggers[lg] {
+				return
+			}
+			seenLoggers[lg] = true
+			for _, s := range lg.sinks {
+				walk(s.w)
+			}
+			return
+		}
+
+		// Not every writer can be a map key. Those that can't might be visited
+		// more than once.
+		if reflect.ValueOf(w).Comparable() {
+			if seen[w] {
+				return
+			}
+			seen[w] = true
+		}
+		ws = append(ws, w)
+		if u, ok := w.(interface{ Unwrap() io.Writer }); ok {
+			walk(u.Unwrap())
+		}
+	}
+	for _, w := range roots {
+		walk(w)
+	}
+	return ws
+}
@@ -0,0 +1,247 @@
+package ln
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// lifecycleSink counts calls to its lifecycle methods, and blocks in Flush
+// until `block` is closed (if not nil).
+//
+// The first Sync and Close close `synced` and `closed` (if not nil), so tests
+// can wait for flushes left running in the background before cleaning up.
+// Otherwise they race with the next test changing the package loggers.
+type lifecycleSink struct {
+	flushes, syncs, closes int
+	block, synced, closed  chan struct{}
+}
+
+func (s *lifecycleSink) Write(p []byte) (int, error) { return len(p), nil }
+func (s *lifecycleSink) Sync() error                 { s.syncs++; closeOnce(&s.synced); return nil }
+func (s *lifecycleSink) Close() error                { s.closes++; closeOnce(&s.closed); return nil }
+
+// closeOnce closes `*c`, if not nil, and clears it.
+func closeOnce(c *chan struct{}) {
+	if *c != nil {
+		close(*c)
+		*c = nil
+	}
+}
+
+func (s *lifecycleSink) Flush() error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.flushes++
+	return nil
+}
+
+// resetLifecycle clears registered sinks and hooks when the test is done.
+func resetLifecycle(t *testing.T) {
+	snap := Snapshot()
+	t.Cleanup(func() {
+		snap.Restore()
+		lifecycleLock.Lock()
+		defer lifecycleLock.Unlock()
+		registered = nil
+		fatalHooks = nil
+	})
+}
+
+// TestFlush verifies Flush finds sinks through Logger chains, wrappers, and
+// RegisterSink, and handles each once.
+func TestFlush(t *testing.T) {
+	resetLifecycle(t)
+
+	direct := new(lifecycleSink)
+	wrapped := new(lifecycleSink)
+	detached := new(lifecycleSink)
+	Info.LogTo(direct, Threshold(LevelError, wrapped))
+	Warning.LogTo(Info, direct)
+	RegisterSink(detached)
+
+	if err := Flush(); err != nil {
+		t.Errorf("unexpected error from Flush: %v", err)
+	}
+	for name, s := range map[string]*lifecycleSink{"direct": direct, "wrapped": wrapped, "detached": detached} {
+		if s.flushes != 1 || s.syncs != 1 {
+			t.Errorf("got %d flushes and %d syncs want 1 of each for %s sink", s.flushes, s.syncs, name)
+		}
+		if s.closes != 0 {
+			t.Errorf("got %d want 0 closes from Flush for %s sink", s.closes, name)
+		}
+	}
+}
+
+// TestScopeFlush verifies a scope's Flush and Shutdown handle its own sinks,
+// which the package Flush does not reach.
+func TestScopeFlush(t *testing.T) {
+	resetLifecycle(t)
+
+	s := NewScope()
+	sink := new(lifecycleSink)
+	s.LogAllTo(sink)
+	if err := Flush(); err != nil {
+		t.Errorf("unexpected error from Flush: %v", err)
+	}
+	if sink.flushes != 0 {
+		t.Errorf("got %d want 0 flushes from the package Flush", sink.flushes)
+	}
+
+	if err := s.Flush(); err != nil {
+		t.Errorf("unexpected error from Scope.Flush: %v", err)
+	}
+	if err := s.Shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected error from Scope.Shutdown: %v", err)
+	}
+	if sink.flushes != 2 || sink.syncs != 2 || sink.closes != 1 {
+		t.Errorf("got %d flushes, %d syncs, and %d closes want 2, 2, and 1", sink.flushes, sink.syncs, sink.closes)
+	}
+}
+
+// TestFlushWhileLogging verifies Flush does not race with messages being
+// written to a buffered sink. Run with -race.
+func TestFlushWhileLogging(t *testing.T) {
+	resetLifecycle(t)
+
+	var out bytes.Buffer
+	b := bufio.NewWriter(&out)
+	LogAllTo(b)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				Info.Print("I while flushing\n")
+			}
+		}()
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for flushing := true; flushing; {
+		select {
+		case <-done:
+			flushing = false
+		default:
+		}
+		if err := Flush(); err != nil {
+			t.Errorf("unexpected error from Flush: %v", err)
+		}
+	}
+	if err := Flush(); err != nil {
+		t.Errorf("unexpected error from Flush: %v", err)
+	}
+
+	if got, want := strings.Count(out.String(), "I while flushing\n"), 4000; got != want {
+		t.Errorf("got %d want %d messages after the final Flush", got, want)
+	}
+}
+
+// TestShutdown verifies Shutdown flushes and closes sinks, and respects the
+// context deadline.
+func TestShutdown(t *testing.T) {
+	resetLifecycle(t)
+
+	s := new(lifecycleSink)
+	LogAllTo(s, os.Stderr)
+	if err := Shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected error from Shutdown: %v", err)
+	}
+	if s.flushes != 1 || s.closes != 1 {
+		t.Errorf("got %d flushes and %d closes want 1 of each", s.flushes, s.closes)
+	}
+
+	// The stuck Shutdown finishes in the background, once released.
+	s = &lifecycleSink{block: make(chan struct{}), closed: make(chan struct{})}
+	defer func(closed chan struct{}) {
+		close(s.block)
+		<-closed
+	}(s.closed)
+	LogAllTo(s)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got %v want %v from Shutdown with a stuck sink", err, context.DeadlineExceeded)
+	}
+}
+
+// TestShutdownWrapped verifies Shutdown closes a wrapper that closes what it
+// wraps, and not the wrapped writer again, while still closing writers wrapped
+// by wrappers that cannot be closed.
+func TestShutdownWrapped(t *testing.T) {
+	resetLifecycle(t)
+
+	path := filepath.Join(t.TempDir(), "a.log")
+	audit, err := OpenAuditFile(path, HMACKey("secret"), AuditOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := new(lifecycleSink)
+	LogAllTo(audit, Threshold(LevelError, s))
+	Info.Print("I before shutdown\n")
+	if err := Shutdown(context.Background()); err != nil {
+		t.Errorf("unexpected error from Shutdown: %v", err)
+	}
+	if s.closes != 1 {
+		t.Errorf("got %d want 1 closes for the sink behind a ThresholdWriter", s.closes)
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err := VerifyAudit(bytes.NewReader(b), HMACKey("secret")); err != nil || res.Records != 1 || res.Unsigned != 0 {
+		t.Errorf("got %+v, %v want 1 signed record:\n%s", res, err, b)
+	}
+}
+
+// TestRunFatalHooks verifies hooks run in order before the final flush, and
+// that a stuck hook cannot delay termination past the timeout.
+func TestRunFatalHooks(t *testing.T) {
+	resetLifecycle(t)
+	defer func(d time.Duration) { FatalHookTimeout = d }(FatalHookTimeout)
+
+	s := new(lifecycleSink)
+	LogAllTo(s)
+	var order []string
+	OnFatal(func() { order = append(order, "first") })
+	OnFatal(func() { panic("bad hook") })
+	OnFatal(func() { order = append(order, "third") })
+
+	RunFatalHooks()
+	if len(order) != 2 || order[0] != "first" || order[1] != "third" {
+		t.Errorf("got %q want [first third] for hooks run", order)
+	}
+	if s.flushes != 1 {
+		t.Errorf("got %d want %d flushes after the hooks", s.flushes, 1)
+	}
+
+	// The stuck hook, and the flush after it, finish in the background once
+	// released.
+	s = &lifecycleSink{synced: make(chan struct{})}
+	LogAllTo(s)
+	stuck := make(chan struct{})
+	defer func(synced chan struct{}) {
+		close(stuck)
+		<-synced
+	}(s.synced)
+	OnFatal(func() { <-stuck })
+	FatalHookTimeout = 10 * time.Millisecond
+	start := time.Now()
+	RunFatalHooks()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("got %v want about %v for RunFatalHooks with a stuck hook", elapsed, FatalHookTimeout)
+	}
+}
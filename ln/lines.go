@@ -0,0 +1,167 @@
+package ln
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// DefaultMaxLine is the longest line a LineWriter logs, if no limit is given.
+const DefaultMaxLine = 64 << 10
+
+// truncatedSuffix marks a line cut short by a LineWriter.
+const truncatedSuffix = " [truncated]"
+
+// LineWriter is an io.Writer that splits a byte stream into lines, and logs
+// each line as a separate message to a Logger.
+//
+// This is the opposite of PrintWriter: it lets output meant for a file or a
+// terminal, like a subprocess's stderr, go through ln. For example:
+//
+//	w := ln.NewLineWriter(ln.Warning, "ffmpeg", 0)
+//	cmd.Stderr = w
+//	err := cmd.Run()
+//	w.Close()
+//
+// Messages are labeled with a fixed source, like "ffmpeg", in place of the
+// usual `Func(file.go:line)`, which would only point at the LineWriter.
+//
+// Lines longer than the limit are cut short and marked " [truncated]", and the
+// rest of the line is dropped. Trailing "\r" is removed, so "\r\n" line endings
+// work.
+//
+// Safe for concurrent use, although lines written by concurrent writers may be
+// mixed up.
+type LineWriter struct {
+	l       Logger
+	cs      *callsite
+	maxLine int
+
+	// keepHeaders passes lines that already have an ln header through as they
+	// are, at their own level, rather than adding another header.
+	keepHeaders bool
+
+	lock     sync.Mutex
+	buf      []byte // The start of a line, waiting for the rest.
+	dropping bool   // Dropping the rest of an overlong line.
+}
+
+// NewLineWriter returns a LineWriter that logs lines to `l`, labeled with
+// `label`.
+//
+// Lines longer than `maxLine` bytes are truncated. If `maxLine` is 0 or less,
+// DefaultMaxLine is used.
+func NewLineWriter(l Logger, label string, maxLine int) *LineWriter {
+	if maxLine <= 0 {
+		maxLine = DefaultMaxLine
+	}
+	return &LineWriter{
+		l:       l,
+		cs:      labelCallsite(label),
+		maxLine: maxLine,
+	}
+}
+
+// Write logs every complete line in `p`, and holds on to any partial line at
+// the end until the rest of it is written.
+//
+// Always consumes all of `p`. Returns the first error from the Logger, if any.
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	n := len(p)
+	var err error
+	keep := func(e error) {
+		if err == nil {
+			err = e
+		}
+	}
+	for len(p) > 0 {
+		line, rest, found := bytes.Cut(p, []byte("\n"))
+		p = rest
+		switch {
+		case w.dropping:
+			w.dropping = !found
+		case len(w.buf)+len(line) > w.maxLine:
+			// Log the start of an overlong line now, rather than holding on to it.
+			w.buf = append(w.buf, line[:w.maxLine-len(w.buf)]...)
+			keep(w.emit(truncatedSuffix))
+			w.dropping = !found
+		default:
+			w.buf = append(w.buf, line...)
+			if found {
+				keep(w.emit(""))
+			}
+		}
+	}
+	return n, err
+}
+
+// Flush logs any partial line being held.
+func (w *LineWriter) Flush() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.dropping = false
+	if len(w.buf) == 0 {
+		return nil
+	}
+	return w.emit("")
+}
+
+// Close logs any partial line being held.
+func (w *LineWriter) Close() error { return w.Flush() }
+
+// emit logs the current line, followed by `suffix`, and starts a new one.
+//
+// The lock must be held.
+func (w *LineWriter) emit(suffix string) error {
+	msg := string(bytes.TrimSuffix(w.buf, []byte("\r"))) + suffix
+	if cap(w.buf) > maxPooledBuffer {
+		w.buf = nil // Don't hold on to a huge buffer.
+	} else {
+		w.buf = w.buf[:0]
+	}
+
+	lg := w.l.getLogger()
+	if lg == nil {
+		return nil
+	}
+	if w.keepHeaders {
+		if level, ok := headerLevel(msg); ok {
+			_, err := lg.WriteLevel(level, []byte(msg+"\n"))
+			return err
+		}
+	}
+	_, err := lg.outputAt(w.cs, msg)
+	return err
+}
+
+// headerLevel returns the level of a message that starts with an ln header,
+// like "I1018 10:15:00.000000 ", or false if it does not start with one.
+func headerLevel(msg string) (Level, bool) {
+	i := strings.IndexFunc(msg, func(r rune) bool { return '0' <= r && r <= '9' })
+	if i <= 0 || len(msg) < i+len("1018 10:15:00.000000 ") {
+		return 0, false
+	}
+	for j, c := range msg[i : i+len("1018 10:15:00.000000 ")] {
+		want := "0000 00:00:00.000000 "[j]
+		if want == '0' && (c < '0' || c > '9') || want != '0' && byte(c) != want {
+			return 0, false
+		}
+	}
+	return levelForPrefix(msg[:i])
+}
+
+// LogLines reads `r` until EOF, and logs each line to `l` like a LineWriter.
+//
+// Returns any error from reading, other than io.EOF.
+func LogLines(r io.Reader, l Logger, label string, maxLine int) error {
+	w := NewLineWriter(l, label, maxLine)
+	_, err := io.Copy(w, r)
+	if e := w.Close(); err == nil {
+		err = e
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package ln
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// lineMessages returns the message text of each line logged to `s`, after the
+// header.
+func lineMessages(t *testing.T, s *sink, label string) []string {
+	t.Helper()
+	header := regexp.MustCompile(`^I\d{4} \d\d:\d\d:\d\d\.\d{6} ` + regexp.QuoteMeta(label) + ` `)
+	var msgs []string
+	for _, line := range strings.SplitAfter(s.String(), "\n") {
+		if line == "" {
+			continue
+		}
+		loc := header.FindStringIndex(line)
+		if loc == nil {
+			t.Errorf("got header %q want label %q", line, label)
+			continue
+		}
+		msgs = append(msgs, strings.TrimSuffix(line[loc[1]:], "\n"))
+	}
+	return msgs
+}
+
+func TestLineWriter(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		writes []string
+		want   []string
+	}{
+		{"whole lines", []string{"one\ntwo\n"}, []string{"one", "two"}},
+		{"split lines", []string{"o", "ne\ntw", "o\nthr"}, []string{"one", "two", "thr"}},
+		{"crlf", []string{"one\r\ntwo\r\n"}, []string{"one", "two"}},
+		{"empty line", []string{"one\n\ntwo\n"}, []string{"one", "", "two"}},
+		{"exactly max", []string{"12345678", "\n"}, []string{"12345678"}},
+		{"too long", []string{"1234567890\nshort\n"}, []string{"12345678 [truncated]", "short"}},
+		{"too long split", []string{"12345", "67890", "abc", "\nshort"}, []string{"12345678 [truncated]", "short"}},
+		{"too long at end", []string{"1234567890"}, []string{"12345678 [truncated]"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := newSink()
+			w := NewLineWriter(NewLevel(LevelInfo, s, nil), "subproc", 8)
+			for _, p := range tc.writes {
+				if n, err := w.Write([]byte(p)); n != len(p) || err != nil {
+					t.Errorf("got %d, %v want %d, nil from Write", n, err, len(p))
+				}
+			}
+			if err := w.Close(); err != nil {
+				t.Errorf("Close: %v", err)
+			}
+			got := lineMessages(t, s, "subproc")
+			if strings.Join(got, "|") != strings.Join(tc.want, "|") {
+				t.Errorf("got %q want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestLogLines(t *testing.T) {
+	s := newSink()
+	r := strings.NewReader("first\nsecond")
+	if err := LogLines(r, NewLevel(LevelInfo, s, nil), "reader", 0); err != nil {
+		t.Fatalf("LogLines: %v", err)
+	}
+	got := lineMessages(t, s, "reader")
+	if strings.Join(got, "|") != "first|second" {
+		t.Errorf("got %q want %q", got, []string{"first", "second"})
+	}
+
+	// A disabled logger logs nothing.
+	if err := LogLines(strings.NewReader("x\n"), NilLogger(), "reader", 0); err != nil {
+		t.Errorf("LogLines: %v", err)
+	}
+}
+
+func TestHeaderLevel(t *testing.T) {
+	for _, tc := range []struct {
+		msg   string
+		level Level
+		ok    bool
+	}{
+		{"I1018 10:15:00.000000 main(main.go:1) hi", LevelInfo, true},
+		{"F1018 10:15:00.000000 #12 ln boom", LevelFatal, true},
+		{"Q1018 10:15:00.000000 unknown prefix", 0, false},
+		{"I1018 10:15:00 short", 0, false},
+		{"goroutine 1 [running]:", 0, false},
+		{"1018 10:15:00.000000 no prefix", 0, false},
+	} {
+		level, ok := headerLevel(tc.msg)
+		if level != tc.level || ok != tc.ok {
+			t.Errorf("got %v, %v want %v, %v for %q", level, ok, tc.level, tc.ok, tc.msg)
+		}
+	}
+}
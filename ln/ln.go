@@ -5,10 +5,11 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path"
+	"reflect"
 	"runtime"
 	"strconv"
-	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,6 +23,9 @@ var (
 
 	// Verbosity provides control over whether the Logger returned by V will do
 	// anything.
+	//
+	// Assigning to it is not synchronized with logging. To change it while other
+	// goroutines are logging, use DefaultScope().SetVerbosity.
 	Verbosity = 0
 
 	// PackageVerbosity provides overrides to the Verbosity based on the package
@@ -32,36 +36,41 @@ var (
 	// Note that package main is always just 'main' and should never have a path.
 	PackageVerbosity = make(map[string]int)
 
+	// Trace logs messages at Trace level, below Debug.
+	Trace = builtin(LevelTrace, "T", os.Stderr, nil)
+
 	// Debug logs messages at Debug level.
-	Debug = New("D", os.Stderr, nil)
+	Debug = builtin(LevelDebug, "D", os.Stderr, nil)
 
 	// Info logs messages at Info level.
-	Info = New("I", os.Stderr, nil)
+	Info = builtin(LevelInfo, "I", os.Stderr, nil)
 
 	// Warning logs messages at Warning level.
-	Warning = New("W", os.Stderr, nil)
+	Warning = builtin(LevelWarning, "W", os.Stderr, nil)
 
 	// Error logs messages at Error level. Syncs after every write.
-	Error = New("E", NewSyncWriter(os.Stderr), nil)
+	Error = builtin(LevelError, "E", NewSyncWriter(os.Stderr), nil)
 
 	// Fatal logs messages at Fatal level, and then terminates the program.
-	Fatal = New("F", NewSyncWriter(os.Stderr), Terminate)
+	Fatal = builtin(LevelFatal, "F", NewSyncWriter(os.Stderr), Terminate)
+
+	// SequenceNumbers adds a sequence number to each message, after the
+	// timestamp, like "#42". Numbers are shared by every logger in the process,
+	// so the order of messages can be reconstructed from several sinks, even when
+	// the timestamps are the same.
+	SequenceNumbers = false
 
 	nilLogger = Logger(func(a ...any) (int, error) {
 		return 0, nil
 	})
 )
 
-// LogAllTo sets up all loggers using the default prefixes & triggers, writing
-// to the given writer.
+// LogAllTo sets up the loggers for every registered level using the default
+// prefixes & triggers, writing to the given writers.
 //
 // Does not set any writers to sync.
-func LogAllTo(w io.Writer) {
-	Debug = New("D", w, nil)
-	Info = New("I", w, nil)
-	Warning = New("W", w, nil)
-	Error = New("E", w, nil)
-	Fatal = New("F", w, Terminate)
+func LogAllTo(writers ...io.Writer) {
+	defaultScope.LogAllTo(writers...)
 }
 
 // MakeLogger is deprecated in favor of `New`, and may be removed in the future.
@@ -72,7 +81,8 @@ func MakeLogger(prefix string, w io.Writer, trigger func()) Logger {
 // New returns a new Logger that writes to `w`.
 //
 // Every line of output will have the given `prefix`. Usually this is a single
-// letter, but in can be anything.
+// letter, but in can be anything. The Logger's Level is the registered level
+// with the same prefix, or LevelInfo if there is none.
 //
 // If not nil, the `trigger` function is called after each message is written.
 // Primarily intended for the Fatal logger implementation, which triggers the
@@ -80,27 +90,48 @@ func MakeLogger(prefix string, w io.Writer, trigger func()) Logger {
 //
 // To sync after each write, wrap the writer in a `NewSyncWriter(w)` call.
 //
-// To write to multiple sinks, wrap with an `io.MultiWriter`.
+// To write to multiple sinks, use LogTo on the returned Logger.
 func New(prefix string, w io.Writer, trigger func()) Logger {
+	level, ok := levelForPrefix(prefix)
+	if !ok {
+		level = LevelInfo
+	}
+	return builtin(level, prefix, w, trigger)
+}
+
+// NewLevel returns a new Logger for the given registered level, using the
+// level's prefix.
+//
+// Otherwise the same as New.
+func NewLevel(level Level, w io.Writer, trigger func()) Logger {
+	return builtin(level, level.Prefix(), w, trigger)
+}
+
+// builtin returns a new Logger without consulting the level registry, so it
+// can be used to initialize the package loggers.
+func builtin(level Level, prefix string, w io.Writer, trigger func()) Logger {
 	lg := &logger{
 		prefix:  prefix,
-		w:       w,
+		level:   level,
 		trigger: trigger,
 	}
+	if w != nil {
+		lg.setSinks([]io.Writer{w})
+	}
 	return newLogger(lg)
 }
 
 func newLogger(lg *logger) Logger {
 	var l Logger = func(a ...any) (int, error) {
 		if len(a) == 1 {
-			if o, ok := a[0].(op); ok {
+			if r, ok := a[0].(*loggerRef); ok {
 				// Implement the magic that lets Logger be a function rather than a
 				// struct, but still have associated data.
-				return o.op(lg)
+				r.lg = lg
+				return 0, nil
 			}
 		}
-		message := assemble(1, lg.prefix, fmt.Sprint(a...))
-		return lg.Write(message)
+		return lg.print(1, a)
 	}
 	return l
 }
@@ -112,6 +143,24 @@ type Config struct {
 	Verbosity                          int
 	PackageVerbosity                   map[string]int
 	Debug, Info, Warning, Error, Fatal Logger
+
+	// VModule is the spec for SetVModule.
+	VModule string
+
+	// Loggers holds the Logger for each other level, like Trace and custom
+	// levels. Entries for the levels above are used where their field is nil.
+	Loggers map[Level]Logger
+}
+
+// fields returns pointers to the Logger fields of the config, by level.
+func (c *Config) fields() map[Level]*Logger {
+	return map[Level]*Logger{
+		LevelDebug:   &c.Debug,
+		LevelInfo:    &c.Info,
+		LevelWarning: &c.Warning,
+		LevelError:   &c.Error,
+		LevelFatal:   &c.Fatal,
+	}
 }
 
 // Restore sets the package settings to the values from the config.
@@ -119,15 +168,12 @@ type Config struct {
 // The PackageVerbosity map is cloned, so changes to the config are not
 // reflected in the package post-restore, and vice-versa.
 //
+// Loggers for levels missing from Loggers are left alone, except the levels
+// with their own fields, which are always set.
+//
 // May be called more than once.
 func (c *Config) Restore() {
-	TZ = c.TZ
-	Verbosity = c.Verbosity
-	PackageVerbosity = make(map[string]int, len(c.PackageVerbosity))
-	for k, v := range c.PackageVerbosity {
-		PackageVerbosity[k] = v
-	}
-	Debug, Info, Warning, Error, Fatal = c.Debug, c.Info, c.Warning, c.Error, c.Fatal
+	defaultScope.Restore(c)
 }
 
 // Snapshot takes a snapshot of the current package settings, to allow for
@@ -136,42 +182,23 @@ func (c *Config) Restore() {
 // The PackageVerbosity map is cloned, so changes post-snapshot are not
 // reflected in the snapshot.
 func Snapshot() *Config {
-	pv := make(map[string]int, len(PackageVerbosity))
-	for k, v := range PackageVerbosity {
-		pv[k] = v
-	}
-	return &Config{
-		TZ:               TZ,
-		Verbosity:        Verbosity,
-		PackageVerbosity: pv,
-		Debug:            Debug.Clone(),
-		Info:             Info.Clone(),
-		Warning:          Warning.Clone(),
-		Error:            Error.Clone(),
-		Fatal:            Fatal.Clone(),
-	}
+	return defaultScope.Snapshot()
 }
 
 // LevelEnabled returns true if a log message at the given level would be
 // passed through from the current file and with the current verbosity settings.
 func LevelEnabled(level int) bool {
-	v := Verbosity
-	if pv, ok := packageVerbosity(1); ok {
-		v = pv
-	}
-	return level <= v
+	return defaultScope.enabled(level, 1)
 }
 
 // V returns the Info logger if the given level is less than or equal to the
 // current Verbosity. Otherwise it returns the nil logger, which throws away
 // everything logged to it.
+//
+// Arguments to the returned Logger are still evaluated when it is the nil
+// logger. Use Func for messages that are expensive to build.
 func V(level int) Logger {
-	v := Verbosity
-	if pv, ok := packageVerbosity(1); ok {
-		v = pv
-	}
-
-	if level <= v {
+	if defaultScope.enabled(level, 1) {
 		return Info
 	}
 	return nilLogger
@@ -209,8 +236,7 @@ func (l Logger) Print(a ...any) (int, error) {
 		return 0, nil
 	}
 
-	message := assemble(1, lg.prefix, fmt.Sprint(a...))
-	return lg.Write(message)
+	return lg.print(1, a)
 }
 
 // Printf writes a formatted result to the Logger, using the same formatting
@@ -221,8 +247,47 @@ func (l Logger) Printf(format string, a ...any) (int, error) {
 		return 0, nil
 	}
 
-	message := assemble(1, lg.prefix, fmt.Sprintf(format, a...))
-	return lg.Write(message)
+	return lg.printf(1, format, a)
+}
+
+// Func writes the result of calling `f` to the Logger.
+//
+// `f` is only called if the Logger is not the nil logger, so expensive messages
+// are only built when they will be logged:
+//
+//	ln.V(3).Func(func() string { return expensiveDump(state) })
+func (l Logger) Func(f func() string) (int, error) {
+	lg := l.getLogger()
+	if lg == nil {
+		return 0, nil
+	}
+
+	cs := callsiteAt(1)
+	if lg.level == LevelDebug && debugDisabled(cs) {
+		return 0, nil
+	}
+	return lg.outputAt(cs, f())
+}
+
+// Enabled returns true unless this is the nil logger.
+//
+// Useful for guarding expensive work done only for logging:
+//
+//	if l := ln.V(3); l.Enabled() {
+//		l.Printf("state: %v", expensiveDump(state))
+//	}
+func (l Logger) Enabled() bool {
+	return l.getLogger() != nil
+}
+
+// Level returns the level of messages logged through the Logger, or 0 for the
+// nil logger.
+func (l Logger) Level() Level {
+	lg := l.getLogger()
+	if lg == nil {
+		return 0
+	}
+	return lg.level
 }
 
 // LogTo changes the io.Writer associated with the Logger.
@@ -239,7 +304,7 @@ func (l Logger) LogTo(writers ...io.Writer) {
 	if lg == nil {
 		return
 	}
-	lg.w = io.MultiWriter(writers...)
+	lg.setSinks(writers)
 }
 
 // Write is a low-level function that forwards its parameter directly to the
@@ -258,6 +323,19 @@ func (l Logger) Write(p []byte) (int, error) {
 	return lg.Write(p)
 }
 
+// WriteLevel is like Write, but passes the given level on to sinks that
+// implement LevelWriter instead of the Logger's own level.
+//
+// This is what lets a sink attached to one Logger see the original level of
+// messages written through other Loggers that log to it.
+func (l Logger) WriteLevel(level Level, p []byte) (int, error) {
+	lg := l.getLogger()
+	if lg == nil {
+		return 0, nil
+	}
+	return lg.WriteLevel(level, p)
+}
+
 // SetTrigger changes the trigger that gets called when anything is written to
 // the Logger.
 //
@@ -279,13 +357,11 @@ func (l Logger) getLogger() *logger {
 		return nil
 	}
 
-	var lg *logger
-	l(op{
-		op: func(lgx *logger) (int, error) {
-			lg = lgx
-			return 0, nil
-		},
-	})
+	r := loggerRefs.Get().(*loggerRef)
+	l(r.args[:]...)
+	lg := r.lg
+	r.lg = nil
+	loggerRefs.Put(r)
 	return lg
 }
 
@@ -319,16 +395,41 @@ func (w PrintWriter) Write(p []byte) (int, error) {
 // Holds the data associated with a Logger.
 type logger struct {
 	prefix  string
-	w       io.Writer // Probably an io.MultiWriter. May be nil.
-	trigger func()    // May be nil.
+	level   Level
+	sinks   []sinkSlot // Replaced, never modified, by setSinks.
+	trigger func()     // May be nil.
+	scope   *Scope     // Nil for the default scope.
 }
 
 func (l *logger) clone() *logger {
-	return &logger{
+	c := &logger{
 		prefix:  l.prefix,
-		w:       l.w,
+		level:   l.level,
 		trigger: l.trigger,
+		scope:   l.scope,
+	}
+	c.setSinks(l.writers())
+	return c
+}
+
+// writers returns the writers the logger writes to.
+func (l *logger) writers() []io.Writer {
+	if l.sinks == nil {
+		return nil
+	}
+	ws := make([]io.Writer, len(l.sinks))
+	for i, s := range l.sinks {
+		ws[i] = s.w
+	}
+	return ws
+}
+
+// tz returns the timezone for the logger's messages.
+func (l *logger) tz() *time.Location {
+	if l.scope != nil {
+		return *l.scope.tz
 	}
+	return TZ
 }
 
 // SyncableWriter is a writer than can Sync its output.
@@ -345,6 +446,12 @@ type SyncWriter struct{ w SyncableWriter }
 // Writes through the returned SyncWriter will call Sync after writing.
 func NewSyncWriter(w SyncableWriter) *SyncWriter { return &SyncWriter{w} }
 
+// Unwrap returns the underlying writer.
+func (w *SyncWriter) Unwrap() io.Writer { return w.w }
+
+// Sync syncs the underlying writer.
+func (w *SyncWriter) Sync() error { return w.w.Sync() }
+
 // Write writes the given data following the contract specified by
 // io.Writer.Write.
 //
@@ -361,18 +468,199 @@ func (w *SyncWriter) Write(p []byte) (n int, err error) {
 	return
 }
 
-// Write writes the given message to the writer associated with the logger.
+// Write writes the given message to the writers associated with the logger,
+// at the logger's level.
 //
 // If the logger has a trigger function, calls it after writing the message.
 func (l *logger) Write(p []byte) (n int, err error) {
+	return l.WriteLevel(l.level, p)
+}
+
+// WriteLevel writes the given message to each of the writers associated with
+// the logger, passing `level` on to those that implement LevelWriter.
+//
+// Each writer gets the message in a single call to Write, and no other message
+// is written to the same writer at the same time, from this or any other
+// logger (see sinkKey).
+//
+// A writer that fails does not stop the message going to the rest, and is
+// handled according to OnSinkError. Returns the first error, and the count from
+// the writer that returned it.
+//
+// If the logger has a trigger function, calls it after writing the message.
+func (l *logger) WriteLevel(level Level, p []byte) (n int, err error) {
 	defer func() {
 		if t := l.trigger; t != nil {
 			t()
 		}
 	}()
 
-	n, err = l.w.Write(p)
-	return
+	n = len(p)
+	for _, s := range l.sinks {
+		if wn, werr := writeSink(s, level, p); werr != nil && err == nil {
+			n, err = wn, werr
+		}
+	}
+	return n, err
+}
+
+// sinkSlot is a writer attached to a logger, along with its state.
+type sinkSlot struct {
+	w  io.Writer
+	st *sinkState // Nil for writers without state (see acquireSinkState).
+}
+
+// sinkState holds the per-sink state shared by every logger writing to the
+// sink.
+type sinkState struct {
+	lock        sync.Mutex   // Serializes writes.
+	consecutive atomic.Int64 // Failed writes since the last successful one.
+
+	key  io.Writer // In sinkStates.
+	refs int       // Slots holding the state. Guarded by sinkStatesLock.
+}
+
+var (
+	sinkStatesLock sync.Mutex
+
+	// sinkStates maps each writer attached to a logger onto its *sinkState. An
+	// entry is removed once no logger holds it, so it does not keep the writer
+	// from being collected.
+	sinkStates = make(map[io.Writer]*sinkState)
+)
+
+// sinkKey returns the key for the state of `w` in sinkStates, or false if it
+// cannot have any.
+//
+// Wrappers with an `Unwrap() io.Writer` method share the state of the writer
+// they wrap, so os.Stderr and NewSyncWriter(os.Stderr) do not interleave.
+// Loggers do not need any state, as they lock their own sinks. Writers that
+// cannot be map keys cannot have state.
+func sinkKey(w io.Writer) (key io.Writer, ok bool) {
+	if w == nil {
+		return nil, false
+	}
+	for {
+		if _, ok := w.(Logger); ok {
+			return nil, false
+		}
+		u, ok := w.(interface{ Unwrap() io.Writer })
+		if !ok || u.Unwrap() == nil {
+			break
+		}
+		w = u.Unwrap()
+	}
+	if !reflect.ValueOf(w).Comparable() {
+		return nil, false
+	}
+	return w, true
+}
+
+// acquireSinkState returns the state for `w`, creating it if necessary, and
+// holds it until released with releaseSinkState. Returns nil if `w` cannot
+// have state.
+func acquireSinkState(w io.Writer) *sinkState {
+	key, ok := sinkKey(w)
+	if !ok {
+		return nil
+	}
+	sinkStatesLock.Lock()
+	defer sinkStatesLock.Unlock()
+	st := sinkStates[key]
+	if st == nil {
+		st = &sinkState{key: key}
+		sinkStates[key] = st
+	}
+	st.refs++
+	return st
+}
+
+// releaseSinkState releases a state returned by acquireSinkState, forgetting it
+// once nothing holds it.
+func releaseSinkState(st *sinkState) {
+	if st == nil {
+		return
+	}
+	sinkStatesLock.Lock()
+	defer sinkStatesLock.Unlock()
+	if st.refs--; st.refs == 0 {
+		delete(sinkStates, st.key)
+	}
+}
+
+// sinkStateFor returns the state for `w`, or nil if no logger is writing to
+// it.
+func sinkStateFor(w io.Writer) *sinkState {
+	key, ok := sinkKey(w)
+	if !ok {
+		return nil
+	}
+	sinkStatesLock.Lock()
+	defer sinkStatesLock.Unlock()
+	return sinkStates[key]
+}
+
+// setSinks attaches `writers` to the logger, in place of its current sinks.
+func (l *logger) setSinks(writers []io.Writer) {
+	sinks := make([]sinkSlot, len(writers))
+	for i, w := range writers {
+		sinks[i] = sinkSlot{w: w, st: acquireSinkState(w)}
+	}
+	l.holdSinks(sinks)
+}
+
+// holdSinks attaches `sinks`, whose states are already held, to the logger,
+// releasing those of its current sinks.
+//
+// The states are also released when the logger is collected, so a logger that
+// is simply dropped does not keep its sinks alive.
+func (l *logger) holdSinks(sinks []sinkSlot) {
+	old := l.sinks
+	l.sinks = sinks
+	for _, s := range old {
+		releaseSinkState(s.st)
+	}
+	if old == nil && sinks != nil {
+		runtime.SetFinalizer(l, (*logger).releaseSinks)
+	}
+}
+
+// releaseSinks releases the states of the logger's sinks, when it is collected.
+func (l *logger) releaseSinks() {
+	for _, s := range l.sinks {
+		releaseSinkState(s.st)
+	}
+}
+
+// writeSink writes `p` to `s` like writeLevel, holding the sink's lock, and
+// applies OnSinkError if it fails.
+//
+// A Logger sink has already applied OnSinkError to its own failing sinks, so
+// its errors are only returned.
+func writeSink(s sinkSlot, level Level, p []byte) (int, error) {
+	n, err := writeLocked(s.st, s.w, level, p)
+	if err == nil && n != len(p) {
+		err = io.ErrShortWrite
+	}
+	if _, ok := s.w.(Logger); ok {
+		return n, err
+	}
+	if err != nil {
+		sinkFailed(s.st, s.w, err)
+	} else if s.st != nil && s.st.consecutive.Load() != 0 {
+		s.st.consecutive.Store(0)
+	}
+	return n, err
+}
+
+// writeLocked is like writeLevel, but holds the lock in `st`, if not nil, while
+// writing.
+func writeLocked(st *sinkState, w io.Writer, level Level, p []byte) (int, error) {
+	if st != nil {
+		st.lock.Lock()
+		defer st.lock.Unlock()
+	}
+	return writeLevel(w, level, p)
 }
 
 // String returns the logger's prefix, or "?".
@@ -387,116 +675,186 @@ func (l *logger) String() string {
 
 // This is a bit of magic that lets Logger be a function instead of a struct.
 //
-// If the one and only parameter to the Logger function is an op, then it calls
-// op.op with the logger captured by the Logger closure, allowing methods on
-// Logger to do what they do.
-type op struct {
-	op func(lg *logger) (n int, err error)
+// If the one and only parameter to the Logger function is a *loggerRef, then
+// the Logger stores the logger captured by its closure in it, allowing methods
+// on Logger to do what they do.
+//
+// The references are pooled, and each holds its own argument slice, so
+// retrieving the logger does not allocate.
+type loggerRef struct {
+	lg   *logger
+	args [1]any // Holds the loggerRef itself.
 }
 
-// assemble concatenates the parts to create a full log message.
-//
-// `skip` specifies how many stack frames to go back (0 = caller of assemble)
-// when gathering callsite information to include in the message.
-//
-// Returns the formatted message, including a newline, as a byte slice.
-func assemble(skip int, prefix string, msg string) []byte {
-	now := time.Now()
-	if tz := TZ; tz != nil {
-		now = now.In(tz)
-	}
+var loggerRefs = sync.Pool{
+	New: func() any {
+		r := new(loggerRef)
+		r.args[0] = r
+		return r
+	},
+}
 
-	file, lineNum, fnc, ok := caller(skip + 1)
-	var line string
-	if ok {
-		line = strconv.Itoa(lineNum)
-	} else {
-		fnc = "????"
-		file = "???"
-		line = "??"
-	}
+// sequence holds the last sequence number used, for SequenceNumbers.
+var sequence atomic.Uint64
 
-	return []byte(fmt.Sprintf("%s%s %s(%s:%s) %s\n",
-		prefix, now.Format("0102 15:04:05.000000"),
-		fnc, file, line,
-		msg))
+// Buffers for assembling messages.
+var buffers = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 256)
+		return &b
+	},
 }
 
-// caller returns the file name (without path), line, and function name
-// (witchout path) of the caller.
+// maxPooledBuffer is the largest buffer that will be returned to the pool, so
+// one huge message does not pin a huge buffer forever.
+const maxPooledBuffer = 64 << 10
+
+// print logs the parameters, formatted as if passed through fmt.Print.
 //
-// Jumps back `skip` frames (0 = caller of `caller`).
-func caller(skip int) (file string, line int, fnc string, ok bool) {
-	file, line, fnc, ok = fullCaller(skip + 1)
-	if !ok {
-		return
+// `skip` specifies how many stack frames to go back (0 = caller of print) when
+// gathering callsite information to include in the message.
+func (l *logger) print(skip int, a []any) (int, error) {
+	cs := callsiteAt(skip + 1)
+	if l.level == LevelDebug && debugDisabled(cs) {
+		return 0, nil
 	}
 
-	file = path.Base(file)
-	dot := strings.LastIndex(fnc, ".")
-	if dot != -1 {
-		fnc = fnc[dot+1:]
+	b := buffers.Get().(*[]byte)
+	defer putBuffer(b)
+
+	*b = appendHeader((*b)[:0], l.tz(), cs, l.prefix)
+	*b = fmt.Append(*b, a...)
+	*b = append(*b, '\n')
+	return l.Write(*b)
+}
+
+// printf is like print, but formats the parameters as if passed through
+// fmt.Printf.
+func (l *logger) printf(skip int, format string, a []any) (int, error) {
+	cs := callsiteAt(skip + 1)
+	if l.level == LevelDebug && debugDisabled(cs) {
+		return 0, nil
 	}
-	return
+
+	b := buffers.Get().(*[]byte)
+	defer putBuffer(b)
+
+	*b = appendHeader((*b)[:0], l.tz(), cs, l.prefix)
+	*b = fmt.Appendf(*b, format, a...)
+	*b = append(*b, '\n')
+	return l.Write(*b)
 }
 
-// fullCaller returns the full file name, line, and full function name of the
-// caller.
-//
-// Jumps back `skip` frames (0 = caller of `fullCaller`).
-func fullCaller(skip int) (file string, line int, fnc string, ok bool) {
-	var pc uintptr
-	pc, file, line, ok = runtime.Caller(skip + 1)
-	if !ok {
-		return
+// outputAt is like print, but logs a message that has already been built, and
+// attributes it to the given callsite, which may be nil if unknown.
+func (l *logger) outputAt(cs *callsite, msg string) (int, error) {
+	b := buffers.Get().(*[]byte)
+	defer putBuffer(b)
+
+	*b = assemble((*b)[:0], l.tz(), cs, l.prefix, msg)
+	return l.Write(*b)
+}
+
+func putBuffer(b *[]byte) {
+	if cap(*b) <= maxPooledBuffer {
+		buffers.Put(b)
 	}
+}
 
-	f := runtime.FuncForPC(pc)
-	fnc = f.Name()
-	return
+// assemble concatenates the parts to create a full log message, and appends it
+// to `b`.
+//
+// `tz` is the timezone for the timestamp, or nil for the default for
+// time.Now(). `cs` is the callsite to include in the message, or nil if
+// unknown.
+//
+// Returns the extended buffer. The message includes a trailing newline.
+func assemble(b []byte, tz *time.Location, cs *callsite, prefix string, msg string) []byte {
+	b = appendHeader(b, tz, cs, prefix)
+	b = append(b, msg...)
+	return append(b, '\n')
 }
 
-// packageName returns both the long and short names of the package of the
-// caller.
+// appendHeader appends everything that comes before the message in a log line,
+// including the trailing space, to `b`.
 //
-// skip = 0 is the caller of `packageName`.
-func packageName(skip int) (long, short string, ok bool) {
-	// Full function name looks like path/to/pkg.Func
-	var f string
-	_, _, f, ok = fullCaller(skip + 1)
-	if !ok {
-		return
+// `tz` is the timezone for the timestamp, or nil for the default for
+// time.Now(). `cs` is the callsite to include in the header, or nil if unknown.
+//
+// This is formatted by hand, rather than with fmt and time.Format, because it
+// is on the path of every message.
+func appendHeader(b []byte, tz *time.Location, cs *callsite, prefix string) []byte {
+	now := time.Now()
+	if tz != nil {
+		now = now.In(tz)
 	}
-
-	// Strip down to path/to/pkg
-	dot := strings.LastIndex(f, ".")
-	if dot == -1 {
-		return
+	_, month, day := now.Date()
+	hour, minute, sec := now.Clock()
+
+	b = append(b, prefix...)
+	b = appendDigits(b, int(month), 2)
+	b = appendDigits(b, day, 2)
+	b = append(b, ' ')
+	b = appendDigits(b, hour, 2)
+	b = append(b, ':')
+	b = appendDigits(b, minute, 2)
+	b = append(b, ':')
+	b = appendDigits(b, sec, 2)
+	b = append(b, '.')
+	b = appendDigits(b, now.Nanosecond()/1000, 6)
+	b = append(b, ' ')
+	if SequenceNumbers {
+		b = append(b, '#')
+		b = strconv.AppendUint(b, sequence.Add(1), 10)
+		b = append(b, ' ')
 	}
-	long = f[:dot]
 
-	// Strip down to pkg
-	slash := strings.LastIndex(long, "/")
-	if slash == -1 {
-		return
+	switch {
+	case cs != nil && cs.label != "":
+		b = append(b, cs.label...)
+		b = append(b, ' ')
+	case cs != nil:
+		b = append(b, cs.fnc...)
+		b = append(b, '(')
+		b = append(b, cs.file...)
+		b = append(b, ':')
+		b = strconv.AppendInt(b, int64(cs.line), 10)
+		b = append(b, ") "...)
+	default:
+		b = append(b, "????(???:??) "...)
 	}
-	short = long[slash+1:]
-	return
+	return b
+}
+
+// appendDigits appends the decimal form of non-negative `n` to `b`, zero-padded
+// to `width` digits.
+func appendDigits(b []byte, n, width int) []byte {
+	var digits [20]byte
+	i := len(digits)
+	for n >= 10 || width > 1 {
+		i--
+		digits[i] = byte('0' + n%10)
+		n /= 10
+		width--
+	}
+	i--
+	digits[i] = byte('0' + n)
+	return append(b, digits[i:]...)
 }
 
-// packageVerbosity returns the package-specific verbosity, or false if not set.
-func packageVerbosity(skip int) (v int, ok bool) {
-	if len(PackageVerbosity) == 0 {
+// packageVerbosity returns the verbosity for the callsite's package from `pv`,
+// or false if not set.
+func (cs *callsite) packageVerbosity(pv map[string]int) (v int, ok bool) {
+	if len(pv) == 0 || cs.longPkg == "" {
 		return
 	}
 
-	long, short, ok := packageName(skip + 1)
-	v, ok = PackageVerbosity[long]
+	v, ok = pv[cs.longPkg]
 	if ok {
 		return
 	}
 
-	v, ok = PackageVerbosity[short]
+	v, ok = pv[cs.shortPkg]
 	return
 }
 
@@ -505,22 +863,5 @@ func packageVerbosity(skip int) (v int, ok bool) {
 //
 // Returns an error on encountering a parse error.
 func ParsePackageVerbosity(s string) error {
-	if s == "" {
-		return nil
-	}
-
-	parts := strings.Split(s, ",")
-	for _, part := range parts {
-		pkg, v, ok := strings.Cut(part, "=")
-		if !ok {
-			return fmt.Errorf("'%s' in package verbosity '%s' not in 'pkg=verbosity' format", part, s)
-		}
-
-		verb, err := strconv.ParseInt(v, 10, 32)
-		if err != nil {
-			return fmt.Errorf("'%s in package verbosity '%s': bad verbosity: %w", part, s, err)
-		}
-		PackageVerbosity[pkg] = int(verb)
-	}
-	return nil
+	return defaultScope.ParsePackageVerbosity(s)
 }
@@ -62,13 +62,13 @@ func (s *sink) trigger()                          { s.triggers++ }
 func (s *sink) Write(p []byte) (n int, err error) { return s.data.Write(p) }
 func (s *sink) String() string                    { return s.data.String() }
 
-type sync struct {
+type syncSink struct {
 	*sink
 	syncs   int
 	syncErr error // Returned from Sync().
 }
 
-func (s *sync) Sync() error {
+func (s *syncSink) Sync() error {
 	s.syncs++
 	return s.syncErr
 }
@@ -392,7 +392,7 @@ func TestAbortMe(t *testing.T) {
 // TestSyncWriter verifies that Sync is called on those writers that have it.
 func TestSyncWriter(t *testing.T) {
 	s1 := newSink()
-	s2 := &sync{
+	s2 := &syncSink{
 		sink: newSink(),
 	}
 	l := New("X", s1, nil)
@@ -512,3 +512,82 @@ func TestSnapshotRestore(t *testing.T) {
 		t.Errorf("got %v want %v calls to trigger0", got, want)
 	}
 }
+
+// TestFunc verifies Func only builds messages for enabled loggers.
+func TestFunc(t *testing.T) {
+	s := newSink()
+	l := New("X", s, nil)
+
+	calls := 0
+	f := func() string {
+		calls++
+		return "lazy message"
+	}
+
+	NilLogger().Func(f)
+	if calls != 0 {
+		t.Errorf("got %d want %d calls from the nil logger", calls, 0)
+	}
+	if NilLogger().Enabled() {
+		t.Errorf("got true want false for NilLogger().Enabled()")
+	}
+
+	// These next two lines must be adjacent.
+	_, line, fnc, _ := caller(0)
+	l.Func(f)
+	if calls != 1 {
+		t.Errorf("got %d want %d calls from an enabled logger", calls, 1)
+	}
+	if !l.Enabled() {
+		t.Errorf("got false want true for l.Enabled()")
+	}
+
+	m := matcher.FindStringSubmatch(s.String())
+	if m == nil {
+		t.Fatalf("got %q which does not match expected line format", s.String())
+	}
+	if m[funcNameIdx] != fnc {
+		t.Errorf("got %q want %q for function", m[funcNameIdx], fnc)
+	}
+	if lineStr := strconv.Itoa(line + 1); m[lineNumberIdx] != lineStr {
+		t.Errorf("got %q want %q for line", m[lineNumberIdx], lineStr)
+	}
+	if m[logMessageIdx] != "lazy message" {
+		t.Errorf("got %q want %q for message", m[logMessageIdx], "lazy message")
+	}
+}
+
+// TestHeaderTimestamp verifies the hand-formatted header matches time.Format.
+func TestHeaderTimestamp(t *testing.T) {
+	defer Snapshot().Restore()
+	TZ = time.FixedZone("test", 0)
+
+	before := time.Now().In(TZ)
+	got := string(appendHeader(nil, TZ, callsiteAt(0), "X"))
+	after := time.Now().In(TZ)
+
+	const layout = "X0102 15:04:05.000000 "
+	ts, err := time.ParseInLocation(layout, got[:len(layout)], TZ)
+	if err != nil {
+		t.Fatalf("got %q which does not parse as %q: %v", got, layout, err)
+	}
+	ts = ts.AddDate(before.Year(), 0, 0)
+	if ts.Before(before.Truncate(time.Microsecond)) || ts.After(after) {
+		t.Errorf("got %v want between %v and %v for header timestamp", ts, before, after)
+	}
+
+	for _, tc := range []struct {
+		n, width int
+		want     string
+	}{
+		{0, 1, "0"},
+		{5, 2, "05"},
+		{12, 2, "12"},
+		{123, 2, "123"},
+		{42, 6, "000042"},
+	} {
+		if got := string(appendDigits(nil, tc.n, tc.width)); got != tc.want {
+			t.Errorf("got %q want %q for appendDigits(%d, %d)", got, tc.want, tc.n, tc.width)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+// Command lnvet reports common mistakes in the use of package ln. See package
+// lnvet for the checks.
+//
+// Usage:
+//
+//	lnvet ./...
+//	go vet -vettool=$(which lnvet) ./...
+package main
+
+import (
+	"github.com/hegh/basics/ln/lnvet"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() { singlechecker.Main(lnvet.Analyzer) }
@@ -0,0 +1,475 @@
+// Package lnvet defines an analyzer that reports common mistakes in the use of
+// package ln:
+//
+//   - format strings passed to Logger.Printf, Timed, TimedAbove, Check, or
+//     DCheck that do not match their arguments
+//   - calling a nil Logger like a function, which panics
+//   - messages logged to ln.Fatal in a library package, which should return an
+//     error instead
+//   - LogTo or LogAllTo calls that make the package loggers write to each other
+//     in a cycle, which loops forever on the first message
+//
+// Run it with go vet:
+//
+//	go install github.com/hegh/basics/ln/lnvet/cmd/lnvet@latest
+//	go vet -vettool=$(which lnvet) ./...
+package lnvet
+
+import (
+	"go/ast"
+	"go/constant"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/buildssa"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+	"golang.org/x/tools/go/ssa"
+)
+
+// lnPath is the import path of package ln.
+const lnPath = "github.com/hegh/basics/ln"
+
+// Analyzer reports common mistakes in the use of package ln.
+var Analyzer = &analysis.Analyzer{
+	Name:     "lnvet",
+	Doc:      "report common mistakes in the use of package ln",
+	URL:      "https://pkg.go.dev/github.com/hegh/basics/ln/lnvet",
+	Requires: []*analysis.Analyzer{inspect.Analyzer, buildssa.Analyzer},
+	Run:      run,
+}
+
+// packageLoggers are the names of the Logger variables in package ln.
+var packageLoggers = []string{"Trace", "Debug", "Info", "Warning", "Error", "Fatal"}
+
+func run(pass *analysis.Pass) (any, error) {
+	if !importsLn(pass.Pkg) {
+		return nil, nil
+	}
+
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	cycles := newLogGraph()
+	ins.Preorder([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node) {
+		call := n.(*ast.CallExpr)
+		checkPrintf(pass, call)
+		checkFatal(pass, call)
+		cycles.add(pass, call)
+	})
+
+	ssaInfo := pass.ResultOf[buildssa.Analyzer].(*buildssa.SSA)
+	for _, fn := range ssaInfo.SrcFuncs {
+		checkNilCalls(pass, fn)
+	}
+	return nil, nil
+}
+
+// importsLn returns true if `pkg` is package ln, or imports it directly.
+func importsLn(pkg *types.Package) bool {
+	if pkg.Path() == lnPath {
+		return true
+	}
+	for _, imp := range pkg.Imports() {
+		if imp.Path() == lnPath {
+			return true
+		}
+	}
+	return false
+}
+
+// isLogger returns true if `t` is ln.Logger.
+func isLogger(t types.Type) bool {
+	named, ok := types.Unalias(t).(*types.Named)
+	if !ok {
+		return false
+	}
+	obj := named.Obj()
+	return obj.Pkg() != nil && obj.Pkg().Path() == lnPath && obj.Name() == "Logger"
+}
+
+// packageLogger returns the name of the ln package Logger variable that `e`
+// refers to, like "Info", or "" if it does not refer to one.
+func packageLogger(info *types.Info, e ast.Expr) string {
+	var id *ast.Ident
+	switch e := ast.Unparen(e).(type) {
+	case *ast.Ident:
+		id = e
+	case *ast.SelectorExpr:
+		id = e.Sel
+	default:
+		return ""
+	}
+	v, ok := info.Uses[id].(*types.Var)
+	if !ok || v.Pkg() == nil || v.Pkg().Path() != lnPath || v.Parent() != v.Pkg().Scope() || !isLogger(v.Type()) {
+		return ""
+	}
+	for _, name := range packageLoggers {
+		if v.Name() == name {
+			return name
+		}
+	}
+	return ""
+}
+
+// loggerMethod returns the name of the Logger method called by `call`, or "" if
+// it does not call a Logger method.
+func loggerMethod(info *types.Info, call *ast.CallExpr) string {
+	sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+	if !ok {
+		return ""
+	}
+	s, ok := info.Selections[sel]
+	if !ok || s.Kind() != types.MethodVal || !isLogger(s.Recv()) {
+		return ""
+	}
+	return sel.Sel.Name
+}
+
+// loggingMethods are the Logger methods that log a message.
+var loggingMethods = map[string]bool{
+	"Print":      true,
+	"Printf":     true,
+	"Func":       true,
+	"Timed":      true,
+	"TimedAbove": true,
+	"Write":      true,
+	"WriteLevel": true,
+}
+
+// checkFatal reports messages logged to ln.Fatal outside of package main and
+// tests. Other uses, like setting up its sinks, are fine.
+//
+// A library that calls Fatal takes the decision to stop the program away from
+// the program.
+func checkFatal(pass *analysis.Pass, call *ast.CallExpr) {
+	if pass.Pkg.Name() == "main" || pass.Pkg.Path() == lnPath {
+		return
+	}
+	l := ast.Unparen(call.Fun)
+	if sel, ok := l.(*ast.SelectorExpr); ok && packageLogger(pass.TypesInfo, sel) != "Fatal" {
+		// Not ln.Fatal(...) itself, so maybe ln.Fatal.Method(...).
+		if !loggingMethods[loggerMethod(pass.TypesInfo, call)] {
+			return
+		}
+		l = sel.X
+	}
+	if packageLogger(pass.TypesInfo, l) != "Fatal" {
+		return
+	}
+	if strings.HasSuffix(pass.Fset.File(call.Pos()).Name(), "_test.go") {
+		return
+	}
+	pass.Reportf(l.Pos(), "ln.Fatal terminates the program; library package %s should return an error instead", pass.Pkg.Name())
+}
+
+// checkNilCalls reports calls to Logger values, like functions, that are or may
+// be nil.
+func checkNilCalls(pass *analysis.Pass, fn *ssa.Function) {
+	for _, b := range fn.Blocks {
+		for _, instr := range b.Instrs {
+			call, ok := instr.(ssa.CallInstruction)
+			if !ok || call.Common().IsInvoke() || !isLogger(call.Common().Value.Type()) {
+				continue
+			}
+			switch nilness(call.Common().Value) {
+			case isNil:
+				pass.Reportf(call.Pos(), "call of nil ln.Logger panics; use Print, which discards the message")
+			case mayBeNil:
+				pass.Reportf(call.Pos(), "call of ln.Logger that may be nil panics if it is; use Print, which discards the message")
+			}
+		}
+	}
+}
+
+type nilState int
+
+const (
+	notNil nilState = iota
+	mayBeNil
+	isNil
+)
+
+// nilness returns whether `v` is nil, as far as can be told without following
+// values through memory.
+func nilness(v ssa.Value) nilState {
+	return nilnessOf(v, make(map[*ssa.Phi]bool))
+}
+
+func nilnessOf(v ssa.Value, seen map[*ssa.Phi]bool) nilState {
+	switch v := v.(type) {
+	case *ssa.Const:
+		if v.IsNil() {
+			return isNil
+		}
+	case *ssa.ChangeType:
+		return nilnessOf(v.X, seen)
+	case *ssa.Phi:
+		if seen[v] {
+			return notNil
+		}
+		seen[v] = true
+		nils := 0
+		for _, e := range v.Edges {
+			switch nilnessOf(e, seen) {
+			case isNil:
+				nils++
+			case mayBeNil:
+				return mayBeNil
+			}
+		}
+		if nils == len(v.Edges) {
+			return isNil
+		} else if nils > 0 {
+			return mayBeNil
+		}
+	}
+	return notNil
+}
+
+// formatIndex maps the Logger methods taking a format string to its index.
+var formatIndex = map[string]int{
+	"Printf":     0,
+	"Timed":      0,
+	"TimedAbove": 1,
+}
+
+// funcFormatIndex maps the package functions taking a format string to its
+// index.
+var funcFormatIndex = map[string]int{
+	"Check":  1,
+	"DCheck": 1,
+}
+
+// checkPrintf reports Logger format strings that do not match their arguments.
+//
+// Only checks constant format strings, and only catches the obvious: the wrong
+// number of arguments, %w, and basic types that cannot print with the verb.
+func checkPrintf(pass *analysis.Pass, call *ast.CallExpr) {
+	method := loggerMethod(pass.TypesInfo, call)
+	idx, ok := formatIndex[method]
+	if !ok {
+		for name, i := range funcFormatIndex {
+			if isLnFunc(pass.TypesInfo, call, name) {
+				method, idx, ok = name, i, true
+			}
+		}
+	}
+	if !ok || len(call.Args) <= idx || call.Ellipsis.IsValid() {
+		return
+	}
+	tv := pass.TypesInfo.Types[call.Args[idx]]
+	if tv.Value == nil || tv.Value.Kind() != constant.String {
+		return
+	}
+	format := constant.StringVal(tv.Value)
+	args := call.Args[idx+1:]
+
+	verbs, ok := parseFormat(format)
+	if !ok {
+		return // Explicit argument indexes; not worth following.
+	}
+	argn := 0
+	for _, v := range verbs {
+		if v.verb == 'w' {
+			pass.Reportf(call.Pos(), "%s does not support error-wrapping directive %%w", method)
+			return
+		}
+		for range v.stars {
+			if argn >= len(args) {
+				break
+			}
+			if t, ok := basic(pass.TypesInfo.TypeOf(args[argn])); ok && t.Info()&types.IsInteger == 0 {
+				pass.Reportf(args[argn].Pos(), "%s format %s uses non-int %s as argument of *", method, v.text, t)
+				return
+			}
+			argn++
+		}
+		if v.verb == '%' {
+			continue
+		}
+		if argn >= len(args) {
+			pass.Reportf(call.Pos(), "%s format %s reads arg #%d, but call has %d %s", method, v.text, argn+1, len(args), plural(len(args), "arg"))
+			return
+		}
+		if t, ok := basic(pass.TypesInfo.TypeOf(args[argn])); ok && !verbAccepts(v.verb, t) {
+			pass.Reportf(args[argn].Pos(), "%s format %s has arg #%d of wrong type %s", method, v.text, argn+1, t)
+			return
+		}
+		argn++
+	}
+	if argn < len(args) {
+		pass.Reportf(call.Pos(), "%s call needs %d %s but has %d %s", method, argn, plural(argn, "arg"), len(args), plural(len(args), "arg"))
+	}
+}
+
+func plural(n int, s string) string {
+	if n == 1 {
+		return s
+	}
+	return s + "s"
+}
+
+// formatVerb is one directive in a format string.
+type formatVerb struct {
+	text  string // Like "%5.2f".
+	verb  rune
+	stars int // Arguments read for the width and precision.
+}
+
+// parseFormat splits a format string into its directives. Returns false if the
+// format uses explicit argument indexes.
+func parseFormat(format string) ([]formatVerb, bool) {
+	var verbs []formatVerb
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		start := i
+		v := formatVerb{}
+		for i++; i < len(format); i++ {
+			c := format[i]
+			switch {
+			case c == '[':
+				return nil, false
+			case c == '*':
+				v.stars++
+			case strings.IndexByte("+-# 0.", c) >= 0 || ('0' <= c && c <= '9'):
+			default:
+				v.verb = rune(c)
+			}
+			if v.verb != 0 {
+				break
+			}
+		}
+		if v.verb == 0 {
+			// Dangling %, which fmt prints as %!(NOVERB).
+			v.verb = '%'
+			i = len(format) - 1
+		}
+		v.text = format[start:min(i+1, len(format))]
+		verbs = append(verbs, v)
+	}
+	return verbs, true
+}
+
+// basic returns `t` if it is a basic type, and not a named type that may have
+// its own String or Format method.
+func basic(t types.Type) (*types.Basic, bool) {
+	b, ok := types.Unalias(t).(*types.Basic)
+	if !ok || b.Kind() == types.Invalid || b.Kind() == types.UntypedNil {
+		return nil, false
+	}
+	return b, true
+}
+
+// verbAccepts returns true if the verb can print a value of basic type `t`.
+func verbAccepts(verb rune, t *types.Basic) bool {
+	info := t.Info()
+	switch verb {
+	case 'v', 'T':
+		return true
+	case 't':
+		return info&types.IsBoolean != 0
+	case 'd', 'c', 'U':
+		return info&types.IsInteger != 0
+	case 'b', 'o', 'O':
+		return info&(types.IsInteger|types.IsFloat|types.IsComplex) != 0
+	case 'x', 'X':
+		return info&(types.IsInteger|types.IsFloat|types.IsComplex|types.IsString) != 0
+	case 'e', 'E', 'f', 'F', 'g', 'G':
+		return info&(types.IsFloat|types.IsComplex) != 0
+	case 's', 'q':
+		return info&types.IsString != 0 || verb == 'q' && info&types.IsInteger != 0
+	case 'p':
+		return t.Kind() == types.UnsafePointer
+	}
+	return true // Unknown verbs are fmt's problem, not ours.
+}
+
+// logGraph tracks which package loggers write to which, by the LogTo and
+// LogAllTo calls seen so far in the package.
+//
+// Calls are taken in source order, each LogTo replacing the earlier sinks of
+// its logger, so this cannot tell which calls actually run. It catches the
+// usual mistake of a setup function pointing loggers at each other.
+type logGraph struct {
+	edges map[string][]string
+}
+
+func newLogGraph() *logGraph {
+	return &logGraph{edges: make(map[string][]string)}
+}
+
+// add records the edges from a LogTo or LogAllTo call, and reports a cycle if
+// they close one.
+func (g *logGraph) add(pass *analysis.Pass, call *ast.CallExpr) {
+	var from []string
+	if method := loggerMethod(pass.TypesInfo, call); method == "LogTo" {
+		sel := ast.Unparen(call.Fun).(*ast.SelectorExpr)
+		name := packageLogger(pass.TypesInfo, sel.X)
+		if name == "" {
+			return
+		}
+		from = []string{name}
+	} else if isLnFunc(pass.TypesInfo, call, "LogAllTo") {
+		from = packageLoggers
+	} else {
+		return
+	}
+
+	var to []string
+	for _, arg := range call.Args {
+		if name := packageLogger(pass.TypesInfo, arg); name != "" {
+			to = append(to, name)
+		}
+	}
+	for _, f := range from {
+		g.edges[f] = to
+	}
+
+	for _, f := range from {
+		if path := g.path(f, f); path != nil {
+			pass.Reportf(call.Pos(), "ln loggers write to each other in a cycle: %s", strings.Join(path, " -> "))
+			return
+		}
+	}
+}
+
+// path returns a path of at least one edge from `from` to `to`, or nil if there
+// is none.
+func (g *logGraph) path(from, to string) []string {
+	seen := make(map[string]bool)
+	var walk func(n string) []string
+	walk = func(n string) []string {
+		for _, next := range g.edges[n] {
+			if next == to {
+				return []string{n, next}
+			}
+			if seen[next] {
+				continue
+			}
+			seen[next] = true
+			if p := walk(next); p != nil {
+				return append([]string{n}, p...)
+			}
+		}
+		return nil
+	}
+	return walk(from)
+}
+
+// isLnFunc returns true if `call` calls the package ln function `name`.
+func isLnFunc(info *types.Info, call *ast.CallExpr, name string) bool {
+	var id *ast.Ident
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return false
+	}
+	fn, ok := info.Uses[id].(*types.Func)
+	return ok && fn.Name() == name && fn.Pkg() != nil && fn.Pkg().Path() == lnPath &&
+		fn.Type().(*types.Signature).Recv() == nil
+}
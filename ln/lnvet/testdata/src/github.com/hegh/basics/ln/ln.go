@@ -0,0 +1,26 @@
+// Package ln is a stub of the real package ln, for testing lnvet.
+package ln
+
+import (
+	"io"
+	"time"
+)
+
+type Logger func(a ...any) (int, error)
+
+func (l Logger) Print(a ...any) (int, error)                 { return 0, nil }
+func (l Logger) Printf(format string, a ...any) (int, error) { return 0, nil }
+func (l Logger) LogTo(writers ...io.Writer)                  {}
+func (l Logger) Enabled() bool                               { return l != nil }
+func (l Logger) Write(p []byte) (int, error)                 { return 0, nil }
+func (l Logger) Timed(format string, a ...any) func()        { return func() {} }
+func (l Logger) TimedAbove(threshold time.Duration, format string, a ...any) func() {
+	return func() {}
+}
+
+var Trace, Debug, Info, Warning, Error, Fatal Logger
+
+func LogAllTo(writers ...io.Writer) {}
+
+func Check(cond bool, format string, a ...any)  {}
+func DCheck(cond bool, format string, a ...any) {}
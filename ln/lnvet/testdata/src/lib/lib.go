@@ -0,0 +1,75 @@
+package lib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/hegh/basics/ln"
+)
+
+type named string
+
+func (n named) String() string { return string(n) }
+
+func printf(n int, s string, f float64, err error) {
+	ln.Info.Printf("%d %s %.2f %v", n, s, f, err)
+	ln.Info.Printf("100%% done")
+	ln.Info.Printf("%*d", n, n)
+	ln.Info.Printf("%[2]d %[1]d", n, n)
+	ln.Info.Printf("%d", named("stringer"))
+	ln.Info.Printf("%x %q", s, n)
+	ln.Info.Printf("%d %d", n)               // want `Printf format %d reads arg #2, but call has 1 arg`
+	ln.Info.Printf("%d", n, n)               // want `Printf call needs 1 arg but has 2 args`
+	ln.Info.Printf("%d", s)                  // want `Printf format %d has arg #1 of wrong type string`
+	ln.Info.Printf("%s", f)                  // want `Printf format %s has arg #1 of wrong type float64`
+	ln.Info.Printf("%*d", s, n)              // want `Printf format %\*d uses non-int string as argument of \*`
+	ln.Info.Printf("failed: %w", err)        // want `Printf does not support error-wrapping directive %w`
+	ln.Warning.Timed("load %s", s, n)        // want `Timed call needs 1 arg but has 2 args`
+	ln.Warning.TimedAbove(time.Second, "%d") // want `TimedAbove format %d reads arg #1, but call has 0 args`
+	ln.Info.Printf(fmt.Sprint("%d"), s)
+	ln.Check(n > 0, "n is %d", n)
+	ln.Check(n > 0, "n is %d")     // want `Check format %d reads arg #1, but call has 0 args`
+	ln.DCheck(n > 0, "n is %d", s) // want `DCheck format %d has arg #1 of wrong type string`
+	_ = errors.New
+	_ = os.Stderr
+}
+
+func nilCalls(cond bool) {
+	var l ln.Logger
+	l("boom") // want `call of nil ln.Logger panics`
+	l.Print("fine")
+
+	var m ln.Logger
+	if cond {
+		m = ln.Info
+	}
+	m("maybe") // want `call of ln.Logger that may be nil panics if it is`
+
+	ln.Logger(nil)("boom") // want `call of nil ln.Logger panics`
+	ln.Info("fine")
+}
+
+func fatal() {
+	ln.Fatal.Print("bye")          // want `ln.Fatal terminates the program; library package lib should return an error instead`
+	ln.Fatal("bye")                // want `ln.Fatal terminates the program`
+	(ln.Fatal).Printf("%s", "bye") // want `ln.Fatal terminates the program`
+
+	// Setting it up, or checking it, does not stop the program.
+	ln.Fatal.LogTo(os.Stderr)
+	if ln.Fatal.Enabled() {
+		ln.Info.Print("fatal messages stop the program")
+	}
+}
+
+func cycles() {
+	ln.Debug.LogTo(ln.Info)
+	ln.Info.LogTo(os.Stderr, ln.Warning)
+	ln.Warning.LogTo(ln.Debug) // want `ln loggers write to each other in a cycle: Warning -> Debug -> Info -> Warning`
+
+	ln.Info.LogTo(os.Stderr)
+	ln.Warning.LogTo(ln.Debug)
+
+	ln.LogAllTo(os.Stderr, ln.Error) // want `ln loggers write to each other in a cycle: .*Error -> Error`
+}
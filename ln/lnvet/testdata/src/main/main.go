@@ -0,0 +1,7 @@
+package main
+
+import "github.com/hegh/basics/ln"
+
+func main() {
+	ln.Fatal.Print("programs may decide to stop")
+}
@@ -0,0 +1,390 @@
+package ln
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Defaults for NetOptions fields left at their zero values.
+const (
+	DefaultMaxSpool     = 64 << 20 // 64 MiB
+	DefaultMinBackoff   = 100 * time.Millisecond
+	DefaultMaxBackoff   = 30 * time.Second
+	DefaultDialTimeout  = 5 * time.Second
+	DefaultWriteTimeout = 5 * time.Second
+)
+
+// NetOptions controls the behavior of a NetWriter.
+//
+// Zero values select the defaults.
+type NetOptions struct {
+	// SpoolPath is the path of a file used to hold messages while the collector
+	// is unreachable. They are replayed, in order, after reconnecting.
+	//
+	// If empty, messages written while disconnected are dropped.
+	//
+	// If the file already holds messages (for example, from a previous run that
+	// could not deliver them), they are replayed on the first connection.
+	SpoolPath string
+
+	// MaxSpool limits the size of the spool file, in bytes. Messages that do not
+	// fit are dropped and counted (see NetWriter.Dropped).
+	MaxSpool int64
+
+	// MinBackoff and MaxBackoff bound the delay between reconnection attempts.
+	// The delay starts at MinBackoff and doubles after each failure, up to
+	// MaxBackoff.
+	MinBackoff, MaxBackoff time.Duration
+
+	// DialTimeout limits each connection attempt.
+	DialTimeout time.Duration
+
+	// WriteTimeout limits each write to the collector, so a stalled collector
+	// cannot block logging indefinitely. A write that times out is treated as a
+	// disconnection.
+	WriteTimeout time.Duration
+}
+
+// NetWriter is an io.Writer that streams newline-delimited log messages to a
+// collector over a TCP or Unix socket.
+//
+// When the connection breaks, a background goroutine reconnects with
+// exponential backoff. In the meantime, messages go to a bounded spool file
+// (if configured), which is replayed when the connection is restored.
+//
+// Each call to Write is treated as one record. A newline is appended if the
+// record does not already end with one.
+//
+// A record that was partially sent when the connection failed is spooled in
+// full, so the collector may see a truncated copy of it followed by the
+// complete record after reconnecting. Records accepted by the kernel before a
+// broken connection is detected may be lost.
+//
+// The spool is replayed in chunks of whole records, each within WriteTimeout,
+// without blocking logging, which keeps going to the spool until the replay
+// catches up. If the connection breaks during the replay, the next one picks
+// up where it stopped. If the program stops during the replay, the records
+// replayed since the last Close may be sent again by the next run.
+//
+// Safe for concurrent use.
+type NetWriter struct {
+	network, addr string
+	opts          NetOptions
+
+	lock    sync.Mutex
+	conn    net.Conn // nil while disconnected.
+	spool   *os.File // nil if not spooling.
+	spooled int64    // Bytes currently in the spool.
+	sent    int64    // Bytes at the start of the spool already replayed.
+	dropped int64    // Records dropped since creation.
+	closed  bool
+
+	broken chan struct{} // Signals the reconnect loop. Buffered.
+	done   chan struct{} // Closed by Close.
+	wg     sync.WaitGroup
+}
+
+// NewNetWriter returns a NetWriter that sends messages to the collector at
+// `addr` on the given `network` ("tcp", "unix", or anything else accepted by
+// net.Dial).
+//
+// Makes one connection attempt before returning. If it fails, the returned
+// writer starts disconnected and keeps trying in the background; the error is
+// not returned.
+//
+// Returns an error only if the spool file cannot be opened.
+//
+// Call Close to stop reconnecting and release the connection and spool file.
+func NewNetWriter(network, addr string, opts NetOptions) (*NetWriter, error) {
+	if opts.MaxSpool <= 0 {
+		opts.MaxSpool = DefaultMaxSpool
+	}
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = DefaultMinBackoff
+	}
+	if opts.MaxBackoff < opts.MinBackoff {
+		opts.MaxBackoff = DefaultMaxBackoff
+		if opts.MaxBackoff < opts.MinBackoff {
+			opts.MaxBackoff = opts.MinBackoff
+		}
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = DefaultDialTimeout
+	}
+	if opts.WriteTimeout <= 0 {
+		opts.WriteTimeout = DefaultWriteTimeout
+	}
+
+	w := &NetWriter{
+		network: network,
+		addr:    addr,
+		opts:    opts,
+		broken:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+
+	if opts.SpoolPath != "" {
+		f, err := os.OpenFile(opts.SpoolPath, os.O_RDWR|os.O_CREATE, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("opening spool file: %w", err)
+		}
+		size, err := f.Seek(0, io.SeekEnd)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("seeking spool file: %w", err)
+		}
+		w.spool = f
+		w.spooled = size
+	}
+
+	if !w.connect() {
+		w.broken <- struct{}{}
+	}
+
+	w.wg.Add(1)
+	go w.reconnect()
+	return w, nil
+}
+
+// Write sends `p` to the collector as a single record, or spools it if the
+// collector is unreachable.
+//
+// Returns `len(p)` if the record was sent, spooled, or dropped because the
+// spool is full. Returns an error only if the writer has been closed.
+func (w *NetWriter) Write(p []byte) (int, error) {
+	rec := p
+	if !bytes.HasSuffix(rec, []byte("\n")) {
+		rec = append(rec[:len(rec):len(rec)], '\n')
+	}
+
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.closed {
+		return 0, net.ErrClosed
+	}
+
+	if w.conn != nil {
+		w.conn.SetWriteDeadline(time.Now().Add(w.opts.WriteTimeout))
+		if _, err := w.conn.Write(rec); err == nil {
+			return len(p), nil
+		}
+		w.disconnect()
+	}
+
+	w.spoolRecord(rec)
+	return len(p), nil
+}
+
+// Connected returns true if the writer currently has a connection to the
+// collector.
+func (w *NetWriter) Connected() bool {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.conn != nil
+}
+
+// Dropped returns the number of records dropped because they were written while
+// disconnected and did not fit in the spool.
+func (w *NetWriter) Dropped() int64 {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.dropped
+}
+
+// Close stops the reconnect loop and closes the connection and spool file.
+//
+// Records still in the spool file stay there, and will be replayed by the next
+// NetWriter to use the same spool path. Those already replayed are removed.
+func (w *NetWriter) Close() error {
+	w.lock.Lock()
+	if w.closed {
+		w.lock.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.done)
+	var err error
+	if w.conn != nil {
+		err = w.conn.Close()
+		w.conn = nil
+	}
+	if w.spool != nil {
+		if serr := w.compactSpool(); err == nil {
+			err = serr
+		}
+		if serr := w.spool.Close(); err == nil {
+			err = serr
+		}
+		w.spool = nil
+	}
+	w.lock.Unlock()
+
+	w.wg.Wait()
+	return err
+}
+
+// spoolRecord appends `rec` to the spool, or drops it if there is no room.
+//
+// Must be called with the lock held.
+func (w *NetWriter) spoolRecord(rec []byte) {
+	if w.spool != nil && w.spooled+int64(len(rec)) > w.opts.MaxSpool && w.sent > 0 {
+		// Make room by removing what was already replayed.
+		if w.compactSpool() != nil {
+			w.dropped++
+			return
+		}
+	}
+	if w.spool == nil || w.spooled+int64(len(rec)) > w.opts.MaxSpool {
+		w.dropped++
+		return
+	}
+
+	n, err := w.spool.WriteAt(rec, w.spooled)
+	w.spooled += int64(n)
+	if err != nil {
+		w.dropped++
+	}
+}
+
+// disconnect closes the current connection and wakes the reconnect loop.
+//
+// Must be called with the lock held.
+func (w *NetWriter) disconnect() {
+	w.conn.Close()
+	w.conn = nil
+	select {
+	case w.broken <- struct{}{}:
+	default:
+	}
+}
+
+// netReplayChunk is the most bytes of the spool sent in one write while
+// replaying it, unless a single record is bigger.
+var netReplayChunk = 64 << 10
+
+// connect makes one attempt to connect to the collector and replay the spool.
+//
+// Returns true on success.
+func (w *NetWriter) connect() bool {
+	conn, err := net.DialTimeout(w.network, w.addr, w.opts.DialTimeout)
+	if err != nil {
+		return false
+	}
+	return w.attach(conn)
+}
+
+// attach replays the spool to `conn`, and then makes it the connection, or
+// closes it if the replay fails.
+//
+// Returns true on success, or if the writer has been closed.
+func (w *NetWriter) attach(conn net.Conn) bool {
+	for {
+		w.lock.Lock()
+		if w.closed {
+			w.lock.Unlock()
+			conn.Close()
+			return true // Stop trying.
+		}
+		if w.sent == w.spooled {
+			// Caught up, so new records can go straight to the collector.
+			if w.spooled > 0 {
+				w.spool.Truncate(0)
+				w.spooled, w.sent = 0, 0
+			}
+			w.conn = conn
+			w.lock.Unlock()
+			return true
+		}
+		chunk, err := w.readChunk()
+		w.lock.Unlock()
+		if err != nil {
+			conn.Close()
+			return false
+		}
+
+		// Logging carries on into the spool meanwhile.
+		conn.SetWriteDeadline(time.Now().Add(w.opts.WriteTimeout))
+		if _, err := conn.Write(chunk); err != nil {
+			conn.Close()
+			return false
+		}
+
+		w.lock.Lock()
+		w.sent += int64(len(chunk))
+		w.lock.Unlock()
+	}
+}
+
+// readChunk returns the next records to replay from the spool, ending with a
+// whole record, so that a broken connection never splits one.
+//
+// Must be called with the lock held.
+func (w *NetWriter) readChunk() ([]byte, error) {
+	size := min(int64(netReplayChunk), w.spooled-w.sent)
+	for {
+		buf := make([]byte, size)
+		if _, err := w.spool.ReadAt(buf, w.sent); err != nil {
+			return nil, err
+		}
+		if i := bytes.LastIndexByte(buf, '\n'); i != -1 {
+			return buf[:i+1], nil
+		}
+		if size == w.spooled-w.sent {
+			return buf, nil // Not a whole record; should not happen.
+		}
+		// A record bigger than a chunk goes by itself.
+		size = min(2*size, w.spooled-w.sent)
+	}
+}
+
+// compactSpool removes the records already replayed from the start of the
+// spool.
+//
+// Must be called with the lock held.
+func (w *NetWriter) compactSpool() error {
+	if w.sent == 0 {
+		return nil
+	}
+	// Reading always stays ahead of writing, so the copy can overlap.
+	n, err := io.Copy(io.NewOffsetWriter(w.spool, 0), io.NewSectionReader(w.spool, w.sent, w.spooled-w.sent))
+	if err == nil {
+		err = w.spool.Truncate(n)
+	}
+	if err != nil {
+		return err
+	}
+	w.spooled, w.sent = n, 0
+	return nil
+}
+
+// reconnect runs in the background, restoring the connection each time it
+// breaks.
+func (w *NetWriter) reconnect() {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case <-w.broken:
+		case <-w.done:
+			return
+		}
+
+		backoff := w.opts.MinBackoff
+		for !w.connect() {
+			select {
+			case <-time.After(backoff):
+			case <-w.done:
+				return
+			}
+			backoff *= 2
+			if backoff > w.opts.MaxBackoff {
+				backoff = w.opts.MaxBackoff
+			}
+		}
+	}
+}
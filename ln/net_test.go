@@ -0,0 +1,272 @@
+package ln
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// collector accepts one connection at a time and forwards each received line
+// to a channel.
+type collector struct {
+	ln    net.Listener
+	lines chan string
+	conns chan net.Conn
+}
+
+func newCollector(t *testing.T, network, addr string) *collector {
+	t.Helper()
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		t.Fatalf("listen on %s %s: %v", network, addr, err)
+	}
+	c := &collector{
+		ln:    l,
+		lines: make(chan string, 100),
+		conns: make(chan net.Conn, 10),
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			c.conns <- conn
+			go func() {
+				s := bufio.NewScanner(conn)
+				for s.Scan() {
+					c.lines <- s.Text()
+				}
+			}()
+		}
+	}()
+	return c
+}
+
+// kill closes the listener and every connection accepted so far.
+func (c *collector) kill() {
+	c.ln.Close()
+	for {
+		select {
+		case conn := <-c.conns:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+// next returns the next line received, or fails the test on timeout.
+func (c *collector) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case line := <-c.lines:
+		return line
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a line at the collector")
+		return ""
+	}
+}
+
+// waitFor polls `cond` until it returns true, or fails the test on timeout.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+var testNetOptions = NetOptions{
+	MinBackoff: time.Millisecond,
+	MaxBackoff: 10 * time.Millisecond,
+}
+
+// TestNetWriterReconnect verifies that messages written while the collector is
+// down are spooled and replayed after it comes back.
+func TestNetWriterReconnect(t *testing.T) {
+	c := newCollector(t, "tcp", "127.0.0.1:0")
+	addr := c.ln.Addr().String()
+
+	opts := testNetOptions
+	opts.SpoolPath = filepath.Join(t.TempDir(), "spool")
+	w, err := NewNetWriter("tcp", addr, opts)
+	if err != nil {
+		t.Fatalf("NewNetWriter: %v", err)
+	}
+	defer w.Close()
+
+	w.Write([]byte("first")) // Newline added by the writer.
+	if got, want := c.next(t), "first"; got != want {
+		t.Errorf("got %q want %q for first line", got, want)
+	}
+
+	// Kill the collector, and keep writing until the writer notices.
+	c.kill()
+	waitFor(t, "disconnection", func() bool {
+		w.Write([]byte("lost?\n"))
+		return !w.Connected()
+	})
+	w.Write([]byte("second\n"))
+	w.Write([]byte("third\n"))
+
+	c = newCollector(t, "tcp", addr)
+	defer c.kill()
+
+	var got []string
+	for len(got) < 2 {
+		if line := c.next(t); line != "lost?" {
+			got = append(got, line)
+		}
+	}
+	if got[0] != "second" || got[1] != "third" {
+		t.Errorf("got %q want [second third] after reconnecting", got)
+	}
+	if got, want := w.Dropped(), int64(0); got != want {
+		t.Errorf("got %d want %d dropped records", got, want)
+	}
+}
+
+// TestNetWriterSpoolLimit verifies that records beyond the spool limit are
+// dropped and counted, over a Unix socket.
+func TestNetWriterSpoolLimit(t *testing.T) {
+	dir := t.TempDir()
+	addr := filepath.Join(dir, "sock")
+
+	opts := testNetOptions
+	opts.SpoolPath = filepath.Join(dir, "spool")
+	opts.MaxSpool = 10
+	w, err := NewNetWriter("unix", addr, opts)
+	if err != nil {
+		t.Fatalf("NewNetWriter: %v", err)
+	}
+	defer w.Close()
+
+	if w.Connected() {
+		t.Fatal("connected with no collector running")
+	}
+	w.Write([]byte("12345\n"))
+	w.Write([]byte("67890\n")) // Does not fit.
+	w.Write([]byte("abc\n"))
+	if got, want := w.Dropped(), int64(1); got != want {
+		t.Errorf("got %d want %d dropped records", got, want)
+	}
+
+	c := newCollector(t, "unix", addr)
+	defer c.kill()
+	if got, want := c.next(t), "12345"; got != want {
+		t.Errorf("got %q want %q for first replayed line", got, want)
+	}
+	if got, want := c.next(t), "abc"; got != want {
+		t.Errorf("got %q want %q for second replayed line", got, want)
+	}
+
+	w.Write([]byte("live\n"))
+	if got, want := c.next(t), "live"; got != want {
+		t.Errorf("got %q want %q for line after reconnecting", got, want)
+	}
+}
+
+// TestNetWriterSpoolSurvivesRestart verifies that records left in the spool by
+// one writer are replayed by the next.
+func TestNetWriterSpoolSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	addr := filepath.Join(dir, "sock")
+
+	opts := testNetOptions
+	opts.SpoolPath = filepath.Join(dir, "spool")
+	w, err := NewNetWriter("unix", addr, opts)
+	if err != nil {
+		t.Fatalf("NewNetWriter: %v", err)
+	}
+	w.Write([]byte("left behind\n"))
+	w.Close()
+
+	if _, err := w.Write([]byte("closed\n")); err == nil {
+		t.Errorf("expected error writing to a closed NetWriter")
+	}
+
+	c := newCollector(t, "unix", addr)
+	defer c.kill()
+	w, err = NewNetWriter("unix", addr, opts)
+	if err != nil {
+		t.Fatalf("NewNetWriter: %v", err)
+	}
+	defer w.Close()
+
+	if got, want := c.next(t), "left behind"; got != want {
+		t.Errorf("got %q want %q for replayed line", got, want)
+	}
+}
+
+// TestNetWriterReplayResumes verifies that the spool is replayed without
+// holding up logging, and a replay that breaks part-way resumes where it
+// stopped on the next connection.
+func TestNetWriterReplayResumes(t *testing.T) {
+	defer func(n int) { netReplayChunk = n }(netReplayChunk)
+	netReplayChunk = 8 // One record per chunk.
+
+	dir := t.TempDir()
+	opts := testNetOptions
+	opts.SpoolPath = filepath.Join(dir, "spool")
+	opts.MinBackoff = time.Hour // Leave connecting to the test.
+	w, err := NewNetWriter("unix", filepath.Join(dir, "sock"), opts)
+	if err != nil {
+		t.Fatalf("NewNetWriter: %v", err)
+	}
+	defer w.Close()
+	for _, rec := range []string{"aaaa\n", "bbbb\n", "cccc\n"} {
+		w.Write([]byte(rec))
+	}
+
+	// Take the first record, then stall the replay.
+	client, server := net.Pipe()
+	attached := make(chan bool)
+	go func() { attached <- w.attach(client) }()
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(server, buf); err != nil {
+		t.Fatalf("reading the first record: %v", err)
+	}
+	if got, want := string(buf), "aaaa\n"; got != want {
+		t.Errorf("got %q want %q for first replayed record", got, want)
+	}
+	written := make(chan struct{})
+	go func() {
+		w.Write([]byte("dddd\n"))
+		close(written)
+	}()
+	select {
+	case <-written:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Write blocked by the replay")
+	}
+	server.Close()
+	if <-attached {
+		t.Errorf("got a successful replay want failure after the collector went away")
+	}
+
+	// Only the records not yet sent are replayed, followed by the new one.
+	client, server = net.Pipe()
+	go func() { attached <- w.attach(client) }()
+	s := bufio.NewScanner(server)
+	var got []string
+	for len(got) < 3 && s.Scan() {
+		got = append(got, s.Text())
+	}
+	if len(got) != 3 || got[0] != "bbbb" || got[1] != "cccc" || got[2] != "dddd" {
+		t.Errorf("got %q want [bbbb cccc dddd] after reconnecting", got)
+	}
+	if !<-attached {
+		t.Errorf("got a failed replay want success")
+	}
+	if !w.Connected() {
+		t.Errorf("got disconnected want connected after the replay")
+	}
+	server.Close()
+}
@@ -0,0 +1,115 @@
+package ln
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// PanicPolicy controls what happens after a recovered panic has been logged.
+type PanicPolicy int
+
+const (
+	// PanicRepanic logs the panic at Error level, then panics again with the
+	// same value.
+	PanicRepanic PanicPolicy = iota
+
+	// PanicSwallow logs the panic at Error level, then returns normally.
+	PanicSwallow
+
+	// PanicFatal logs the panic through the Fatal logger, which runs its trigger
+	// (by default, terminating the program). If the trigger returns, so does the
+	// recovering function.
+	PanicFatal
+)
+
+// OnPanic is the policy used by RecoverAndLog and Go.
+var OnPanic = PanicRepanic
+
+// RecoverAndLog recovers from a panic, logs it along with the stack, and then
+// follows the OnPanic policy.
+//
+// Must be called directly by `defer`:
+//
+//	defer ln.RecoverAndLog()
+//
+// The log message is attributed to the function that panicked.
+func RecoverAndLog() {
+	if r := recover(); r != nil {
+		handlePanic(OnPanic, r, nil, 1)
+	}
+}
+
+// Recover is like RecoverAndLog, but follows the receiver policy instead of
+// OnPanic.
+//
+// Must be called directly by `defer`:
+//
+//	defer ln.PanicSwallow.Recover()
+func (p PanicPolicy) Recover() {
+	if r := recover(); r != nil {
+		handlePanic(p, r, nil, 1)
+	}
+}
+
+// Go runs `f` in a new goroutine. If `f` panics, the panic is logged along with
+// the stack and the callsite of Go, and then the OnPanic policy is followed.
+func Go(f func()) {
+	spawner := callsiteAt(1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				handlePanic(OnPanic, r, spawner, 1)
+			}
+		}()
+		f()
+	}()
+}
+
+// handlePanic logs a recovered panic value `r` and follows the policy `p`.
+//
+// `spawner` is the callsite that started the goroutine, if known.
+//
+// `skip` is the number of frames between handlePanic and the runtime's panic
+// machinery (1 = the caller of handlePanic is the deferred function).
+func handlePanic(p PanicPolicy, r any, spawner *callsite, skip int) {
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "panic: %v", r)
+	if spawner != nil {
+		fmt.Fprintf(&msg, "\ngoroutine started at %s(%s:%d)", spawner.fnc, spawner.file, spawner.line)
+	}
+	msg.WriteString("\n")
+	msg.Write(debug.Stack())
+
+	site := panicSite(skip + 1)
+	if p == PanicFatal {
+		if lg := Fatal.getLogger(); lg != nil {
+			lg.outputAt(site, msg.String())
+		}
+		return
+	}
+
+	if lg := Error.getLogger(); lg != nil {
+		lg.outputAt(site, msg.String())
+	}
+	if p == PanicRepanic {
+		panic(r)
+	}
+}
+
+// panicSite returns the callsite of the function that panicked, or nil.
+//
+// Jumps back `skip` frames (0 = caller of `panicSite`) to reach the runtime's
+// panic machinery, then skips over it.
+func panicSite(skip int) *callsite {
+	var pcs [32]uintptr
+	n := runtime.Callers(skip+2, pcs[:])
+	for _, pc := range pcs[:n] {
+		cs := callsiteFor(pc)
+		if cs != nil && !strings.HasPrefix(cs.fullFnc, "runtime.") {
+			return cs
+		}
+	}
+	return nil
+}
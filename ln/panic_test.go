@@ -0,0 +1,108 @@
+package ln
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// panicker panics with the given value. It is a separate function so the test
+// can verify the panic is attributed to it.
+func panicker(v any) {
+	panic(v)
+}
+
+// captureErrors points Error and Fatal at a buffer, with a counting trigger on
+// Fatal, until the returned restore function is called.
+func captureErrors() (buf *bytes.Buffer, fatals *int, restore func()) {
+	snap := Snapshot()
+	buf = new(bytes.Buffer)
+	fatals = new(int)
+	Error = New("E", buf, nil)
+	Fatal = New("F", buf, func() { *fatals++ })
+	return buf, fatals, snap.Restore
+}
+
+// TestRecoverAndLogSwallow verifies a swallowed panic is logged at Error with
+// the value, stack, and panicking function.
+func TestRecoverAndLogSwallow(t *testing.T) {
+	buf, fatals, restore := captureErrors()
+	defer restore()
+
+	func() {
+		defer PanicSwallow.Recover()
+		panicker("swallowed value")
+	}()
+
+	out := buf.String()
+	m := matcher.FindStringSubmatch(out)
+	if m == nil {
+		t.Fatalf("got %q which does not match expected line format", out)
+	}
+	if m[prefixIdx] != "E" {
+		t.Errorf("got %q want %q for prefix", m[prefixIdx], "E")
+	}
+	if m[funcNameIdx] != "panicker" {
+		t.Errorf("got %q want %q for function", m[funcNameIdx], "panicker")
+	}
+	if m[logMessageIdx] != "panic: swallowed value" {
+		t.Errorf("got %q want %q for message", m[logMessageIdx], "panic: swallowed value")
+	}
+	if !strings.Contains(out, "runtime/debug.Stack") {
+		t.Errorf("got %q want a stack trace", out)
+	}
+	if *fatals != 0 {
+		t.Errorf("got %d want %d fatal triggers", *fatals, 0)
+	}
+}
+
+// TestRecoverAndLogRepanic verifies the default policy logs and panics again.
+func TestRecoverAndLogRepanic(t *testing.T) {
+	buf, _, restore := captureErrors()
+	defer restore()
+
+	var got any
+	func() {
+		defer func() { got = recover() }()
+		defer RecoverAndLog()
+		panicker("repanicked value")
+	}()
+
+	if got != "repanicked value" {
+		t.Errorf("got %v want %v recovered after RecoverAndLog", got, "repanicked value")
+	}
+	if !strings.Contains(buf.String(), "panic: repanicked value") {
+		t.Errorf("got %q want the panic logged", buf)
+	}
+}
+
+// TestGoFatal verifies panics in goroutines started by Go are logged with the
+// spawning callsite, and that the Fatal policy runs the Fatal trigger.
+func TestGoFatal(t *testing.T) {
+	buf, fatals, restore := captureErrors()
+	defer restore()
+	OnPanic = PanicFatal
+	defer func() { OnPanic = PanicRepanic }()
+
+	// The Fatal trigger runs after the message is written.
+	done := make(chan struct{})
+	Fatal.SetTrigger(func() {
+		*fatals++
+		close(done)
+	})
+	Go(func() {
+		panicker("goroutine value")
+	})
+	<-done
+
+	out := buf.String()
+	if !strings.HasPrefix(out, "F") {
+		t.Errorf("got %q want a Fatal message", out)
+	}
+	if !strings.Contains(out, "panic: goroutine value\ngoroutine started at TestGoFatal(panic_test.go:") {
+		t.Errorf("got %q want the panic value and spawning callsite", out)
+	}
+	if *fatals != 1 {
+		t.Errorf("got %d want %d fatal triggers", *fatals, 1)
+	}
+}
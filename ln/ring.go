@@ -0,0 +1,210 @@
+package ln
+
+import (
+	"html/template"
+	"net/http"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultRingMessages is the number of messages a RingWriter keeps for each
+// level if it is given no limits.
+const DefaultRingMessages = 1000
+
+// RingWriter is a LevelWriter that keeps the most recent messages for each
+// level in memory, for display by its ServeHTTP method.
+//
+// Limits apply to each level separately, so a flood of Info messages does not
+// push out the last few Errors.
+//
+// Safe for concurrent use.
+type RingWriter struct {
+	maxMessages int
+	maxBytes    int
+
+	lock  sync.Mutex
+	seq   uint64 // Orders messages across levels.
+	rings map[Level]*ring
+}
+
+// RingEntry is a message held by a RingWriter.
+type RingEntry struct {
+	Level   Level
+	Message string // Includes the trailing newline, if any.
+
+	seq uint64
+}
+
+// ring holds the retained messages for one level, oldest first.
+type ring struct {
+	entries []RingEntry
+	bytes   int
+}
+
+// NewRingWriter returns a RingWriter that keeps, for each level, up to
+// `maxMessages` messages using up to `maxBytes` bytes of message text.
+//
+// A limit of 0 or less means no limit. If neither is set, the message limit is
+// DefaultRingMessages, so the buffer cannot grow without bound.
+func NewRingWriter(maxMessages, maxBytes int) *RingWriter {
+	if maxMessages <= 0 && maxBytes <= 0 {
+		maxMessages = DefaultRingMessages
+	}
+	return &RingWriter{
+		maxMessages: maxMessages,
+		maxBytes:    maxBytes,
+		rings:       make(map[Level]*ring),
+	}
+}
+
+// Write keeps `p` as an Info message, as its level is unknown.
+func (w *RingWriter) Write(p []byte) (int, error) {
+	return w.WriteLevel(LevelInfo, p)
+}
+
+// WriteLevel keeps `p` as a message at `level`, discarding the oldest messages
+// at that level to stay within the limits.
+//
+// A single message larger than the byte limit is still kept, by itself.
+func (w *RingWriter) WriteLevel(level Level, p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	r := w.rings[level]
+	if r == nil {
+		r = new(ring)
+		w.rings[level] = r
+	}
+
+	w.seq++
+	r.entries = append(r.entries, RingEntry{Level: level, Message: string(p), seq: w.seq})
+	r.bytes += len(p)
+
+	drop := 0
+	for drop < len(r.entries)-1 &&
+		((w.maxMessages > 0 && len(r.entries)-drop > w.maxMessages) ||
+			(w.maxBytes > 0 && r.bytes > w.maxBytes)) {
+		r.bytes -= len(r.entries[drop].Message)
+		drop++
+	}
+	if drop > 0 {
+		// Copy down, rather than reslicing, so the backing array does not grow
+		// without bound.
+		n := copy(r.entries, r.entries[drop:])
+		clear(r.entries[n:])
+		r.entries = r.entries[:n]
+	}
+	return len(p), nil
+}
+
+// Entries returns the retained messages at or above `minLevel` that match `re`
+// (if not nil), oldest first.
+func (w *RingWriter) Entries(minLevel Level, re *regexp.Regexp) []RingEntry {
+	// Filter on a copy, so a slow regexp does not hold up logging.
+	w.lock.Lock()
+	var es []RingEntry
+	for level, r := range w.rings {
+		if level >= minLevel {
+			es = append(es, r.entries...)
+		}
+	}
+	w.lock.Unlock()
+
+	if re != nil {
+		es = slices.DeleteFunc(es, func(e RingEntry) bool { return !re.MatchString(e.Message) })
+	}
+	sort.Slice(es, func(i, j int) bool { return es[i].seq < es[j].seq })
+	return es
+}
+
+// ringPage is the HTML page served by RingWriter.ServeHTTP.
+var ringPage = template.Must(template.New("ring").Parse(`<!DOCTYPE html>
+<html>
+<head><title>Recent log messages</title></head>
+<body>
+<form method="get">
+<label>Minimum level <select name="level">
+{{- range .Levels}}
+<option value="{{.Name}}"{{if .Selected}} selected{{end}}>{{.Name}}</option>
+{{- end}}
+</select></label>
+<label>Matching <input type="text" name="re" value="{{.Re}}" size="40"></label>
+<input type="submit" value="Filter">
+</form>
+{{with .Err}}<p style="color: red">{{.}}</p>{{end}}
+<p>{{len .Entries}} messages, oldest first.</p>
+<pre>
+{{- range .Entries}}{{.Message}}{{end -}}
+</pre>
+</body>
+</html>
+`))
+
+// ServeHTTP displays the retained messages, oldest first, filtered by these
+// optional query parameters:
+//   - level: The minimum level to display, in any form accepted by ParseLevel.
+//   - re: A regular expression the messages must match.
+//   - format: "text" for plain text instead of HTML.
+//
+// For example, to see the last few Warning and Error messages on a debug page:
+//
+//	http.Handle("/debug/logs", ring)
+//	// Visit /debug/logs?level=warning
+func (w *RingWriter) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	q := req.URL.Query()
+
+	var errs []string
+	minLevel := Level(0)
+	if s := q.Get("level"); s != "" {
+		l, err := ParseLevel(s)
+		if err != nil {
+			errs = append(errs, err.Error())
+		}
+		minLevel = l
+	}
+	var re *regexp.Regexp
+	if s := q.Get("re"); s != "" {
+		var err error
+		if re, err = regexp.Compile(s); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+
+	if q.Get("format") == "text" {
+		rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		if len(errs) > 0 {
+			http.Error(rw, strings.Join(errs, "\n"), http.StatusBadRequest)
+			return
+		}
+		for _, e := range w.Entries(minLevel, re) {
+			rw.Write([]byte(e.Message))
+		}
+		return
+	}
+
+	type level struct {
+		Name     string
+		Selected bool
+	}
+	data := struct {
+		Levels  []level
+		Re      string
+		Err     string
+		Entries []RingEntry
+	}{
+		Re:  q.Get("re"),
+		Err: strings.Join(errs, "; "),
+	}
+	for _, l := range Levels() {
+		data.Levels = append(data.Levels, level{Name: l.String(), Selected: l == minLevel})
+	}
+	if len(errs) == 0 {
+		data.Entries = w.Entries(minLevel, re)
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	ringPage.Execute(rw, data)
+}
@@ -0,0 +1,107 @@
+package ln
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// messages returns the text of each entry.
+func messages(es []RingEntry) []string {
+	var ms []string
+	for _, e := range es {
+		ms = append(ms, strings.TrimSuffix(e.Message, "\n"))
+	}
+	return ms
+}
+
+// TestRingWriterLimits verifies each level keeps its own most recent messages
+// within the limits.
+func TestRingWriterLimits(t *testing.T) {
+	w := NewRingWriter(2, 0)
+	w.WriteLevel(LevelError, []byte("e1\n"))
+	for _, m := range []string{"i1\n", "i2\n", "i3\n"} {
+		w.WriteLevel(LevelInfo, []byte(m))
+	}
+
+	got := strings.Join(messages(w.Entries(0, nil)), " ")
+	if want := "e1 i2 i3"; got != want {
+		t.Errorf("got %q want %q with a 2-message limit", got, want)
+	}
+
+	w = NewRingWriter(0, 6)
+	for _, m := range []string{"i1\n", "i2\n", "i3\n"} {
+		w.Write([]byte(m))
+	}
+	w.Write([]byte("too big for the limit\n"))
+	got = strings.Join(messages(w.Entries(0, nil)), " ")
+	if want := "too big for the limit"; got != want {
+		t.Errorf("got %q want %q with a 6-byte limit", got, want)
+	}
+}
+
+// TestRingWriterDefaultLimit verifies a RingWriter given no limits still has
+// one.
+func TestRingWriterDefaultLimit(t *testing.T) {
+	w := NewRingWriter(0, 0)
+	for i := 0; i <= DefaultRingMessages; i++ {
+		w.Write([]byte("m\n"))
+	}
+	if got, want := len(w.Entries(0, nil)), DefaultRingMessages; got != want {
+		t.Errorf("got %d want %d messages with no limits given", got, want)
+	}
+}
+
+// TestRingWriterFilter verifies Entries filters by level and regexp.
+func TestRingWriterFilter(t *testing.T) {
+	w := NewRingWriter(10, 0)
+	l := New("I", w, nil)
+	warning := New("W", l, nil)
+	errorL := New("E", l, nil)
+
+	l("info apple")
+	warning("warning apple")
+	errorL("error banana")
+	warning("warning banana")
+
+	got := messages(w.Entries(LevelWarning, regexp.MustCompile("banana")))
+	if len(got) != 2 || !strings.HasSuffix(got[0], "error banana") || !strings.HasSuffix(got[1], "warning banana") {
+		t.Errorf("got %q want the error and warning banana messages, in order", got)
+	}
+}
+
+// TestRingWriterServeHTTP verifies the viewer filters by query parameters and
+// escapes messages.
+func TestRingWriterServeHTTP(t *testing.T) {
+	w := NewRingWriter(10, 0)
+	w.WriteLevel(LevelInfo, []byte("info <b>apple</b>\n"))
+	w.WriteLevel(LevelWarning, []byte("warning apple\n"))
+	w.WriteLevel(LevelError, []byte("error banana\n"))
+
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		w.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/logs?"+query, nil))
+		return rec
+	}
+
+	rec := get("level=W&re=apple&format=text")
+	if got, want := rec.Body.String(), "warning apple\n"; got != want {
+		t.Errorf("got %q want %q for text output", got, want)
+	}
+
+	rec = get("")
+	body := rec.Body.String()
+	if !strings.Contains(body, "info &lt;b&gt;apple&lt;/b&gt;") {
+		t.Errorf("got %q want escaped messages in HTML output", body)
+	}
+	if !strings.Contains(body, "error banana") {
+		t.Errorf("got %q want all messages in HTML output", body)
+	}
+
+	rec = get("level=bogus&format=text")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got %d want %d for a bad level", rec.Code, http.StatusBadRequest)
+	}
+}
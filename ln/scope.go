@@ -0,0 +1,362 @@
+package ln
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+)
+
+// Scope holds a complete logging configuration: a Logger for each level,
+// verbosity settings, and a timezone.
+//
+// The package functions and variables (V, Info, Verbosity, TZ, and so on) make
+// up the default scope, returned by DefaultScope. A scope from NewScope is
+// independent of them, so a library or a test can have its own configuration
+// without disturbing anybody else's:
+//
+//	s := ln.NewScope()
+//	s.LogAllTo(ln.PrintWriter{t.Log})
+//	s.SetVerbosity(2)
+//	s.V(2).Print("only shows up in this test")
+//
+// Like the package variables, the settings are not synchronized with logging.
+// Change them before logging, or only from one goroutine. The exception is the
+// verbosity, which SetVerbosity changes atomically, so it can be changed while
+// other goroutines are logging (as HandleSignals does).
+type Scope struct {
+	// These point at the package variables for the default scope.
+	tz               **time.Location
+	verbosity        *int
+	packageVerbosity *map[string]int
+	vmodule          *atomic.Pointer[vmoduleRules]
+
+	lock    *sync.RWMutex // Guards loggers. The registry lock, for the default scope.
+	loggers map[Level]*Logger
+	info    *Logger // Returned by V.
+}
+
+// defaultScope is made up of the package variables. Its loggers are shared with
+// the level registry, and set up along with it, as the loggers depend on it.
+var defaultScope = &Scope{
+	tz:               &TZ,
+	verbosity:        &Verbosity,
+	packageVerbosity: &PackageVerbosity,
+	vmodule:          &vmodule,
+	lock:             &levelLock,
+}
+
+// DefaultScope returns the scope used by the package functions and variables.
+func DefaultScope() *Scope { return defaultScope }
+
+// NewScope returns a new scope with the default settings, and a Logger writing
+// to os.Stderr for every level registered so far. Like the package loggers,
+// Error and Fatal sync after each write, and Fatal terminates the program.
+//
+// Levels registered later have no Logger in the scope until LogAllTo is called.
+func NewScope() *Scope {
+	s := &Scope{
+		tz:               new(*time.Location),
+		verbosity:        new(int),
+		packageVerbosity: new(map[string]int),
+		vmodule:          new(atomic.Pointer[vmoduleRules]),
+		lock:             new(sync.RWMutex),
+		loggers:          make(map[Level]*Logger),
+	}
+	*s.packageVerbosity = make(map[string]int)
+	for level, info := range levelInfos() {
+		var w io.Writer = os.Stderr
+		if level >= LevelError {
+			w = NewSyncWriter(os.Stderr)
+		}
+		l := s.newLogger(level, info, []io.Writer{w})
+		s.loggers[level] = &l
+	}
+	s.info = s.loggers[LevelInfo]
+	return s
+}
+
+// newLogger returns a new Logger at `level` belonging to the scope.
+func (s *Scope) newLogger(level Level, info levelInfo, sinks []io.Writer) Logger {
+	lg := &logger{
+		prefix:  info.prefix,
+		level:   level,
+		trigger: info.trigger,
+	}
+	lg.setSinks(sinks)
+	if s != defaultScope {
+		lg.scope = s
+	}
+	return newLogger(lg)
+}
+
+// Logger returns the scope's Logger for `level`, or the nil logger if it has
+// none.
+func (s *Scope) Logger(level Level) Logger {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	if l, ok := s.loggers[level]; ok {
+		return *l
+	}
+	return nilLogger
+}
+
+// Trace returns the scope's Logger for LevelTrace.
+func (s *Scope) Trace() Logger { return s.Logger(LevelTrace) }
+
+// Debug returns the scope's Logger for LevelDebug.
+func (s *Scope) Debug() Logger { return s.Logger(LevelDebug) }
+
+// Info returns the scope's Logger for LevelInfo.
+func (s *Scope) Info() Logger { return s.Logger(LevelInfo) }
+
+// Warning returns the scope's Logger for LevelWarning.
+func (s *Scope) Warning() Logger { return s.Logger(LevelWarning) }
+
+// Error returns the scope's Logger for LevelError.
+func (s *Scope) Error() Logger { return s.Logger(LevelError) }
+
+// Fatal returns the scope's Logger for LevelFatal.
+func (s *Scope) Fatal() Logger { return s.Logger(LevelFatal) }
+
+// LogAllTo sets up the scope's loggers for every registered level using the
+// default prefixes & triggers, writing to the given writers.
+//
+// Does not set any writers to sync.
+func (s *Scope) LogAllTo(writers ...io.Writer) {
+	writers = append([]io.Writer(nil), writers...)
+	infos := levelInfos()
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for level, info := range infos {
+		l := s.newLogger(level, info, writers)
+		if p, ok := s.loggers[level]; ok {
+			*p = l
+		} else {
+			s.loggers[level] = &l
+		}
+	}
+}
+
+// TZ returns the timezone used for the scope's log messages, or nil for the
+// default for time.Now().
+func (s *Scope) TZ() *time.Location { return *s.tz }
+
+// SetTZ sets the timezone used for the scope's log messages. If it is nil, uses
+// the default for time.Now().
+func (s *Scope) SetTZ(tz *time.Location) { *s.tz = tz }
+
+// Verbosity returns the verbosity for callers without a package or file
+// override.
+func (s *Scope) Verbosity() int { return loadInt(s.verbosity) }
+
+// SetVerbosity sets the verbosity for callers without a package or file
+// override.
+//
+// Safe to call while other goroutines are logging, unlike assigning to the
+// package Verbosity.
+func (s *Scope) SetVerbosity(v int) { storeInt(s.verbosity, v) }
+
+// loadInt atomically loads the int at `p`.
+//
+// The verbosity is an int, rather than an atomic type, because the package
+// Verbosity is, so it can be passed to flag.IntVar.
+func loadInt(p *int) int {
+	if strconv.IntSize == 64 {
+		return int(atomic.LoadInt64((*int64)(unsafe.Pointer(p))))
+	}
+	return int(atomic.LoadInt32((*int32)(unsafe.Pointer(p))))
+}
+
+// storeInt atomically stores `v` in the int at `p`.
+func storeInt(p *int, v int) {
+	if strconv.IntSize == 64 {
+		atomic.StoreInt64((*int64)(unsafe.Pointer(p)), int64(v))
+	} else {
+		atomic.StoreInt32((*int32)(unsafe.Pointer(p)), int32(v))
+	}
+}
+
+// SetPackageVerbosity overrides the verbosity for a package, by its short name
+// or full path, like PackageVerbosity.
+func (s *Scope) SetPackageVerbosity(pkg string, v int) { (*s.packageVerbosity)[pkg] = v }
+
+// ParsePackageVerbosity parses the given string of comma-separated
+// `package=verbosity` strings and merges them into the scope's package
+// overrides.
+//
+// Returns an error on encountering a parse error.
+func (s *Scope) ParsePackageVerbosity(spec string) error {
+	if spec == "" {
+		return nil
+	}
+
+	parts := strings.Split(spec, ",")
+	for _, part := range parts {
+		pkg, v, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("'%s' in package verbosity '%s' not in 'pkg=verbosity' format", part, spec)
+		}
+
+		verb, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return fmt.Errorf("'%s in package verbosity '%s': bad verbosity: %w", part, spec, err)
+		}
+		(*s.packageVerbosity)[pkg] = int(verb)
+	}
+	return nil
+}
+
+// SetVModule sets the scope's per-file verbosity overrides. See the package
+// SetVModule for the format.
+func (s *Scope) SetVModule(spec string) error {
+	rs, err := parseVModule(spec)
+	if err != nil {
+		return err
+	}
+	s.vmodule.Store(rs)
+	return nil
+}
+
+// VModule returns the spec most recently passed to the scope's SetVModule.
+func (s *Scope) VModule() string {
+	if rs := s.vmodule.Load(); rs != nil {
+		return rs.spec
+	}
+	return ""
+}
+
+// LevelEnabled returns true if a log message at the given level would be
+// passed through from the current file and with the scope's verbosity settings.
+func (s *Scope) LevelEnabled(level int) bool {
+	return s.enabled(level, 1)
+}
+
+// V returns the scope's Info logger if the given level is less than or equal to
+// the verbosity that applies to the caller. Otherwise it returns the nil
+// logger.
+func (s *Scope) V(level int) Logger {
+	if s.enabled(level, 1) {
+		return *s.info
+	}
+	return nilLogger
+}
+
+// enabled returns true if messages at the given verbosity level are enabled
+// for the caller, by the scope's verbosity settings or the callsite's state.
+//
+// Jumps back `skip` frames (0 = caller of `enabled`) to find the caller.
+func (s *Scope) enabled(level, skip int) bool {
+	pv := *s.packageVerbosity
+	rs := s.vmodule.Load()
+	dynamic := dynamicCallsites()
+	if len(pv) == 0 && rs == nil && !dynamic {
+		return level <= loadInt(s.verbosity)
+	}
+
+	cs := callsiteAt(skip + 1)
+	if cs == nil {
+		return level <= loadInt(s.verbosity)
+	}
+	if dynamic {
+		cs.record(callsiteV, level)
+		switch cs.state() {
+		case CallsiteEnabled:
+			return true
+		case CallsiteDisabled:
+			return false
+		}
+	}
+	return level <= s.verbosityFor(cs, pv, rs)
+}
+
+// verbosityFor returns the verbosity that applies to `cs`, given the scope's
+// package verbosity `pv` and file rules `rs`.
+func (s *Scope) verbosityFor(cs *callsite, pv map[string]int, rs *vmoduleRules) int {
+	if v, ok := cs.moduleVerbosity(rs); ok {
+		return v
+	}
+	if v, ok := cs.packageVerbosity(pv); ok {
+		return v
+	}
+	return loadInt(s.verbosity)
+}
+
+// Snapshot takes a snapshot of the scope's settings, to allow for easy
+// restoration.
+//
+// The package verbosity map is cloned, so changes post-snapshot are not
+// reflected in the snapshot.
+func (s *Scope) Snapshot() *Config {
+	pv := make(map[string]int, len(*s.packageVerbosity))
+	for k, v := range *s.packageVerbosity {
+		pv[k] = v
+	}
+
+	c := &Config{
+		TZ:               *s.tz,
+		Verbosity:        loadInt(s.verbosity),
+		PackageVerbosity: pv,
+		VModule:          s.VModule(),
+		Loggers:          make(map[Level]Logger),
+	}
+	fields := c.fields()
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for level, l := range s.loggers {
+		if f, ok := fields[level]; ok {
+			*f = l.Clone()
+		} else {
+			c.Loggers[level] = l.Clone()
+		}
+	}
+	return c
+}
+
+// Restore sets the scope's settings to the values from the config.
+//
+// The PackageVerbosity map is cloned, so changes to the config are not
+// reflected in the scope post-restore, and vice-versa.
+//
+// The Debug, Info, Warning, Error, and Fatal fields are always set, from
+// Loggers if the field is nil and Loggers has the level. Loggers for other
+// levels missing from Loggers are left alone. Loggers for levels the scope does
+// not have are added, except to the default scope, which only has Loggers for
+// registered levels.
+func (s *Scope) Restore(c *Config) {
+	*s.tz = c.TZ
+	storeInt(s.verbosity, c.Verbosity)
+	pv := make(map[string]int, len(c.PackageVerbosity))
+	for k, v := range c.PackageVerbosity {
+		pv[k] = v
+	}
+	*s.packageVerbosity = pv
+	s.SetVModule(c.VModule) // Came from VModule, so it can't fail.
+
+	ls := make(map[Level]Logger, len(c.Loggers)+5)
+	for level, l := range c.Loggers {
+		ls[level] = l
+	}
+	for level, f := range c.fields() {
+		if _, ok := ls[level]; !ok || *f != nil {
+			ls[level] = *f
+		}
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for level, l := range ls {
+		if p, ok := s.loggers[level]; ok {
+			*p = l
+		} else if s != defaultScope {
+			l := l
+			s.loggers[level] = &l
+		}
+	}
+}
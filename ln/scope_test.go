@@ -0,0 +1,134 @@
+package ln
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestScopeIsolation verifies a scope's settings do not affect the package, and
+// scopes can be used from parallel tests.
+func TestScopeIsolation(t *testing.T) {
+	for i := 0; i < 4; i++ {
+		i := i
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			t.Parallel()
+			s := NewScope()
+			sink := newSink()
+			s.LogAllTo(sink)
+			s.SetVerbosity(i)
+			s.SetTZ(time.FixedZone("scope", (i+1)*3600))
+
+			before := time.Now().In(s.TZ())
+			for v := 0; v < 4; v++ {
+				s.V(v).Printf("v%d", v)
+			}
+			after := time.Now().In(s.TZ())
+			got := sink.String()
+			if n := strings.Count(got, "\n"); n != i+1 {
+				t.Errorf("got %d messages want %d:\n%s", n, i+1, got)
+			}
+
+			// Timestamps are in the scope's timezone. Logging may straddle an
+			// hour, so either side of it will do.
+			if hour := got[6:8]; hour != before.Format("15") && hour != after.Format("15") {
+				t.Errorf("got hour %s want %s or %s:\n%s", hour, before.Format("15"), after.Format("15"), got)
+			}
+		})
+	}
+}
+
+func TestScopeSettings(t *testing.T) {
+	defer Snapshot().Restore()
+	Verbosity = 0
+	PackageVerbosity = make(map[string]int)
+	SetVModule("")
+
+	s := NewScope()
+	s.SetVerbosity(1)
+	s.SetPackageVerbosity(shortPackageName, 2)
+	if err := s.ParsePackageVerbosity("other=5"); err != nil {
+		t.Fatalf("ParsePackageVerbosity: %v", err)
+	}
+	if !s.LevelEnabled(2) || s.LevelEnabled(3) {
+		t.Errorf("got %v, %v want true, false for scope LevelEnabled(2), LevelEnabled(3)", s.LevelEnabled(2), s.LevelEnabled(3))
+	}
+	if err := s.SetVModule("scope_test=4"); err != nil {
+		t.Fatalf("SetVModule: %v", err)
+	}
+	if !s.LevelEnabled(4) || s.LevelEnabled(5) {
+		t.Errorf("got %v, %v want true, false for scope LevelEnabled(4), LevelEnabled(5)", s.LevelEnabled(4), s.LevelEnabled(5))
+	}
+
+	// The package settings are untouched.
+	if LevelEnabled(1) || VModule() != "" || len(PackageVerbosity) != 0 {
+		t.Errorf("scope settings leaked into the package: LevelEnabled(1) = %v, VModule() = %q, PackageVerbosity = %v",
+			LevelEnabled(1), VModule(), PackageVerbosity)
+	}
+
+	// Restore undoes changes made after the snapshot, including loggers.
+	c := s.Snapshot()
+	sink := newSink()
+	s.LogAllTo(sink)
+	s.SetVerbosity(9)
+	s.SetVModule("")
+	s.Restore(c)
+	if s.Verbosity() != 1 || s.VModule() != "scope_test=4" {
+		t.Errorf("got %d, %q want 1, scope_test=4 after Restore", s.Verbosity(), s.VModule())
+	}
+	s.Warning().Print("to stderr")
+	if sink.String() != "" {
+		t.Errorf("got %q want nothing written to the replaced sink", sink.String())
+	}
+}
+
+func TestDefaultScope(t *testing.T) {
+	defer Snapshot().Restore()
+	s := DefaultScope()
+
+	Verbosity = 3
+	if s.Verbosity() != 3 {
+		t.Errorf("got %d want %d for DefaultScope().Verbosity()", s.Verbosity(), 3)
+	}
+	s.SetVerbosity(1)
+	if Verbosity != 1 {
+		t.Errorf("got %d want %d for Verbosity", Verbosity, 1)
+	}
+
+	sink := newSink()
+	s.LogAllTo(sink)
+	Info("message")
+	s.V(1).Print("message")
+	if n := strings.Count(sink.String(), "\n"); n != 2 {
+		t.Errorf("got %d messages want 2:\n%s", n, sink.String())
+	}
+}
+
+// TestScopeLevels verifies scopes see levels registered before they were
+// created, and pick up later ones with LogAllTo.
+func TestScopeLevels(t *testing.T) {
+	s := NewScope()
+	if err := RegisterLevel(35, "notice", "N"); err != nil {
+		t.Fatalf("RegisterLevel: %v", err)
+	}
+	defer func() {
+		levelLock.Lock()
+		delete(levels, 35)
+		delete(loggers, 35)
+		levelLock.Unlock()
+	}()
+
+	if s.Logger(35).Enabled() {
+		t.Errorf("got an enabled Logger for a level registered after the scope")
+	}
+	sink := newSink()
+	s.LogAllTo(sink)
+	s.Logger(35).Print("notice")
+	if got := sink.String(); !strings.HasPrefix(got, "N") {
+		t.Errorf("got %q want a message with prefix N", got)
+	}
+	if s.Logger(LevelFatal).getLogger().trigger == nil {
+		t.Errorf("got no trigger want Terminate for the scope's Fatal logger")
+	}
+}
@@ -0,0 +1,56 @@
+package ln
+
+import "fmt"
+
+// HandleSignals makes SIGUSR1 raise Verbosity by one, and SIGUSR2 reset it to
+// the value it had when HandleSignals was called. Each change is logged to
+// Info.
+//
+// Raising the verbosity past `maxVerbosity` cycles it back to the baseline, so
+// an operator can step through the levels with `kill -USR1` alone. If
+// `maxVerbosity` is not above the baseline, there is no cap.
+//
+// The verbosity is changed with SetVerbosity, so logging on other goroutines
+// carries on safely, and sees the new verbosity from its next call to V.
+//
+// Call the returned function to stop handling the signals. Does nothing on
+// platforms without SIGUSR1 and SIGUSR2.
+func HandleSignals(maxVerbosity int) (stop func()) {
+	return defaultScope.HandleSignals(maxVerbosity)
+}
+
+// HandleSignals is like the package HandleSignals, for the scope's verbosity,
+// logging to the scope's Info logger.
+func (s *Scope) HandleSignals(maxVerbosity int) (stop func()) {
+	return handleSignals(&verbositySignals{scope: s, baseline: s.Verbosity(), maxVerbosity: maxVerbosity})
+}
+
+// verbositySignals changes a scope's verbosity in response to signals.
+type verbositySignals struct {
+	scope        *Scope
+	baseline     int
+	maxVerbosity int
+}
+
+// raise raises the verbosity by one, or back to the baseline past the cap.
+func (v *verbositySignals) raise(signal string) {
+	verbosity := v.scope.Verbosity() + 1
+	if v.maxVerbosity > v.baseline && verbosity > v.maxVerbosity {
+		verbosity = v.baseline
+	}
+	v.scope.SetVerbosity(verbosity)
+	v.log(fmt.Sprintf("%s: verbosity set to %d", signal, verbosity))
+}
+
+// reset sets the verbosity back to the baseline.
+func (v *verbositySignals) reset(signal string) {
+	v.scope.SetVerbosity(v.baseline)
+	v.log(fmt.Sprintf("%s: verbosity reset to %d", signal, v.baseline))
+}
+
+// log logs a change to the scope's Info logger, labeled "ln".
+func (v *verbositySignals) log(msg string) {
+	if lg := v.scope.Info().getLogger(); lg != nil {
+		lg.outputAt(labelCallsite("ln"), msg)
+	}
+}
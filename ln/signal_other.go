@@ -0,0 +1,8 @@
+//go:build !unix
+
+package ln
+
+// handleSignals does nothing, as there are no SIGUSR1 and SIGUSR2 here.
+func handleSignals(v *verbositySignals) (stop func()) {
+	return func() {}
+}
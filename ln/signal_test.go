@@ -0,0 +1,109 @@
+//go:build unix
+
+package ln
+
+import (
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// lineChan is a sink sending each message to a channel.
+type lineChan chan string
+
+func (c lineChan) Write(p []byte) (int, error) {
+	c <- string(p)
+	return len(p), nil
+}
+
+func TestHandleSignals(t *testing.T) {
+	s := NewScope()
+	lines := make(lineChan, 10)
+	s.LogAllTo(lines)
+	s.SetVerbosity(1)
+	stop := s.HandleSignals(3)
+	defer stop()
+
+	send := func(sig syscall.Signal, want string) {
+		t.Helper()
+		if err := syscall.Kill(syscall.Getpid(), sig); err != nil {
+			t.Fatalf("failed to send %v: %v", sig, err)
+		}
+		select {
+		case line := <-lines:
+			if !strings.HasSuffix(line, " ln "+want+"\n") {
+				t.Errorf("got %q want suffix %q", line, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no message after %v", sig)
+		}
+	}
+
+	send(syscall.SIGUSR1, "SIGUSR1: verbosity set to 2")
+	send(syscall.SIGUSR1, "SIGUSR1: verbosity set to 3")
+	send(syscall.SIGUSR1, "SIGUSR1: verbosity set to 1") // Past the cap.
+	send(syscall.SIGUSR1, "SIGUSR1: verbosity set to 2")
+	send(syscall.SIGUSR2, "SIGUSR2: verbosity reset to 1")
+	send(syscall.SIGUSR1, "SIGUSR1: verbosity set to 2")
+
+	// Only safe to look at once the handler has stopped.
+	stop()
+	stop() // Safe to call twice.
+	if got, want := s.Verbosity(), 2; got != want {
+		t.Errorf("got verbosity %d want %d", got, want)
+	}
+}
+
+// TestHandleSignalsWhileLogging verifies the verbosity can be changed by a
+// signal while other goroutines are logging. Run with -race.
+func TestHandleSignalsWhileLogging(t *testing.T) {
+	s := NewScope()
+	lines := make(lineChan, 100)
+	s.LogAllTo(lines)
+	stop := s.HandleSignals(0)
+	defer stop()
+
+	done := make(chan struct{})
+	logged := make(chan struct{})
+	go func() {
+		defer close(logged)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			s.V(100).Print("never logged\n")
+			s.LevelEnabled(1)
+		}
+	}()
+
+	for i := 1; i <= 3; i++ {
+		if err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1); err != nil {
+			t.Fatalf("failed to send SIGUSR1: %v", err)
+		}
+		select {
+		case <-lines:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no message after SIGUSR1")
+		}
+	}
+	close(done)
+	<-logged
+	if got, want := s.Verbosity(), 3; got != want {
+		t.Errorf("got verbosity %d want %d", got, want)
+	}
+}
+
+func TestHandleSignalsNoCap(t *testing.T) {
+	v := &verbositySignals{scope: NewScope(), baseline: 2, maxVerbosity: 0}
+	v.scope.LogAllTo(newSink())
+	v.scope.SetVerbosity(2)
+	for i := 0; i < 5; i++ {
+		v.raise("SIGUSR1")
+	}
+	if got, want := v.scope.Verbosity(), 7; got != want {
+		t.Errorf("got verbosity %d want %d", got, want)
+	}
+}
@@ -0,0 +1,43 @@
+//go:build unix
+
+package ln
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// handleSignals calls `v` for each SIGUSR1 and SIGUSR2, until stopped.
+func handleSignals(v *verbositySignals) (stop func()) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case sig := <-sigs:
+				if sig == syscall.SIGUSR1 {
+					v.raise("SIGUSR1")
+				} else {
+					v.reset("SIGUSR2")
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(sigs)
+			close(done)
+			<-stopped
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package ln
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSinkErrorInterval is the minimum time between reports of failed
+// writes, if SinkErrorPolicy.Interval is not set.
+const DefaultSinkErrorInterval = time.Minute
+
+// SinkErrorPolicy controls what happens when a logger fails to write to one of
+// its sinks.
+//
+// Zero values select the defaults.
+type SinkErrorPolicy struct {
+	// Fallback is where failures are reported. Defaults to os.Stderr.
+	Fallback io.Writer
+
+	// Interval is the minimum time between reports. Failures in between are
+	// counted, and the count included in the next report. Defaults to
+	// DefaultSinkErrorInterval.
+	Interval time.Duration
+
+	// FatalAfter triggers the Fatal logger after this many consecutive failed
+	// writes to the same sink. Defaults to never.
+	//
+	// Only sinks that can be map keys are counted, so it never triggers for
+	// others, like a PrintWriter value, which holds a func. Log to a pointer to
+	// such a sink, like &PrintWriter{...}, to have it counted.
+	FatalAfter int
+}
+
+// OnSinkError is the policy followed when a logger fails to write to a sink.
+//
+// Failed writes are still returned to the caller, as always, but callers
+// rarely check them.
+var OnSinkError SinkErrorPolicy
+
+var (
+	sinkFailures   atomic.Int64 // Total, for SinkFailures.
+	unreported     atomic.Int64 // Since the last report.
+	lastReport     atomic.Int64 // Unix nanoseconds, or 0 for never.
+	fatalFromSinks atomic.Bool  // Set while triggering Fatal, to avoid recursion.
+)
+
+// SinkFailures returns the number of failed writes to sinks since the program
+// started.
+func SinkFailures() int64 {
+	return sinkFailures.Load()
+}
+
+// sinkFailed applies OnSinkError to a failed write of `w`, with state `st`
+// (which may be nil).
+func sinkFailed(st *sinkState, w io.Writer, err error) {
+	sinkFailures.Add(1)
+	var consecutive int64
+	if st != nil {
+		consecutive = st.consecutive.Add(1)
+	}
+
+	policy := OnSinkError
+	reportSinkError(policy, w, err)
+
+	if policy.FatalAfter > 0 && consecutive >= int64(policy.FatalAfter) &&
+		fatalFromSinks.CompareAndSwap(false, true) {
+		// Fatal may well write to the failing sink too.
+		defer fatalFromSinks.Store(false)
+		if lg := LevelFatal.Logger().getLogger(); lg != nil {
+			lg.outputAt(labelCallsite("ln"), fmt.Sprintf("%d consecutive failed writes to %T: %v", consecutive, w, err))
+		}
+	}
+}
+
+// reportSinkError writes a report of a failed write to the policy's fallback,
+// unless one was written too recently.
+func reportSinkError(policy SinkErrorPolicy, w io.Writer, err error) {
+	interval := policy.Interval
+	if interval <= 0 {
+		interval = DefaultSinkErrorInterval
+	}
+	now := time.Now().UnixNano()
+	last := lastReport.Load()
+	if (last != 0 && now-last < int64(interval)) || !lastReport.CompareAndSwap(last, now) {
+		unreported.Add(1)
+		return
+	}
+
+	msg := fmt.Sprintf("failed to write log message to %T: %v", w, err)
+	if n := unreported.Swap(0); n > 0 {
+		msg += fmt.Sprintf(" (and %d more failures since the last report)", n)
+	}
+
+	fallback := policy.Fallback
+	if fallback == nil {
+		fallback = os.Stderr
+	}
+	b := buffers.Get().(*[]byte)
+	defer putBuffer(b)
+	*b = assemble((*b)[:0], TZ, labelCallsite("ln"), LevelError.Prefix(), msg)
+	// Locked like any other sink, so the report does not interleave with
+	// messages logged to the fallback. Nowhere left to report a failure.
+	writeLocked(sinkStateFor(fallback), fallback, LevelError, *b)
+}
@@ -0,0 +1,146 @@
+package ln
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func resetSinkErrors(t *testing.T) {
+	policy := OnSinkError
+	snap := Snapshot()
+	t.Cleanup(func() {
+		OnSinkError = policy
+		snap.Restore()
+		lastReport.Store(0)
+		unreported.Store(0)
+	})
+	lastReport.Store(0)
+	unreported.Store(0)
+}
+
+// TestSinkErrorReports verifies failures are reported once per interval, with
+// a count of those in between.
+func TestSinkErrorReports(t *testing.T) {
+	resetSinkErrors(t)
+	fallback := newSink()
+	OnSinkError = SinkErrorPolicy{Fallback: fallback, Interval: time.Hour}
+
+	l := NewLevel(LevelInfo, failingSink{}, nil)
+	before := SinkFailures()
+	for i := 0; i < 3; i++ {
+		l.Print("message")
+	}
+	if got := SinkFailures() - before; got != 3 {
+		t.Errorf("got %d want %d failures counted", got, 3)
+	}
+	got := fallback.String()
+	if strings.Count(got, "\n") != 1 || !strings.Contains(got, " ln failed to write log message to ln.failingSink: disk full\n") {
+		t.Errorf("got %q want one report of the failure", got)
+	}
+	if !strings.HasPrefix(got, "E") {
+		t.Errorf("got %q want an Error message", got)
+	}
+
+	// Once the interval is up, the next report counts the ones skipped.
+	lastReport.Store(time.Now().Add(-2 * time.Hour).UnixNano())
+	fallback.data.Reset()
+	l.Print("message")
+	if got := fallback.String(); !strings.Contains(got, "(and 2 more failures since the last report)") {
+		t.Errorf("got %q want a count of the skipped reports", got)
+	}
+}
+
+// TestSinkErrorChained verifies a failure behind a chained Logger is counted
+// and reported once, naming the failing sink rather than the Logger.
+func TestSinkErrorChained(t *testing.T) {
+	resetSinkErrors(t)
+	fallback := newSink()
+	OnSinkError = SinkErrorPolicy{Fallback: fallback}
+
+	Info.LogTo(failingSink{})
+	Warning.LogTo(Info)
+	before := SinkFailures()
+	if _, err := Warning.Print("message"); err == nil {
+		t.Errorf("got no error from a Logger chained to a failing sink")
+	}
+	if got := SinkFailures() - before; got != 1 {
+		t.Errorf("got %d want %d failures counted", got, 1)
+	}
+	got := fallback.String()
+	if strings.Count(got, "\n") != 1 || !strings.Contains(got, "to ln.failingSink: disk full") {
+		t.Errorf("got %q want one report naming the failing sink", got)
+	}
+}
+
+// TestSinkErrorReportLocked verifies a report takes the fallback's lock, like
+// any other write, so it cannot interleave with messages logged there.
+func TestSinkErrorReportLocked(t *testing.T) {
+	resetSinkErrors(t)
+	fallback := newSink()
+	OnSinkError = SinkErrorPolicy{Fallback: fallback, Interval: time.Hour}
+
+	// Only writers something logs to have a lock to take.
+	logged := NewLevel(LevelInfo, fallback, nil)
+	defer runtime.KeepAlive(logged)
+	st := sinkStateFor(fallback)
+	st.lock.Lock()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewLevel(LevelInfo, failingSink{}, nil).Print("message")
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if got := fallback.String(); got != "" {
+		t.Errorf("got %q want no report while the fallback is locked", got)
+	}
+	st.lock.Unlock()
+	<-done
+	if got := fallback.String(); !strings.Contains(got, "failed to write log message") {
+		t.Errorf("got %q want a report once the fallback is unlocked", got)
+	}
+}
+
+// TestSinkErrorFatal verifies Fatal is triggered after enough consecutive
+// failures to one sink, once, even though Fatal writes to the failing sink.
+func TestSinkErrorFatal(t *testing.T) {
+	resetSinkErrors(t)
+	OnSinkError = SinkErrorPolicy{Fallback: newSink(), Interval: time.Hour, FatalAfter: 3}
+
+	bad := &toggleSink{fail: true}
+	fatal := newSink()
+	Fatal.LogTo(bad, fatal)
+	Fatal.SetTrigger(fatal.trigger)
+	l := NewLevel(LevelInfo, bad, nil)
+
+	l.Print("one")
+	l.Print("two")
+	bad.fail = false
+	l.Print("three") // Resets the count.
+	bad.fail = true
+	l.Print("four")
+	l.Print("five")
+	if fatal.triggers != 0 {
+		t.Fatalf("got %d want 0 Fatal triggers before 3 consecutive failures", fatal.triggers)
+	}
+	l.Print("six")
+	if fatal.triggers != 1 {
+		t.Errorf("got %d want 1 Fatal trigger", fatal.triggers)
+	}
+	if got := fatal.String(); !strings.Contains(got, "ln 3 consecutive failed writes to *ln.toggleSink: disk full") {
+		t.Errorf("got %q want a Fatal message about the failures", got)
+	}
+}
+
+// toggleSink fails while `fail` is set.
+type toggleSink struct {
+	fail bool
+}
+
+func (s *toggleSink) Write(p []byte) (int, error) {
+	if s.fail {
+		return failingSink{}.Write(p)
+	}
+	return len(p), nil
+}
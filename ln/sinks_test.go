@@ -0,0 +1,170 @@
+package ln
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"maps"
+	"regexp"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// slowSink writes one byte at a time, yielding between bytes, and counts the
+// writes that overlapped with another.
+type slowSink struct {
+	writing  atomic.Bool
+	overlaps atomic.Int32
+	data     []byte
+}
+
+func (s *slowSink) Write(p []byte) (int, error) {
+	if !s.writing.CompareAndSwap(false, true) {
+		s.overlaps.Add(1)
+		return 0, errors.New("overlapping write")
+	}
+	defer s.writing.Store(false)
+	for _, c := range p {
+		s.data = append(s.data, c)
+		runtime.Gosched()
+	}
+	return len(p), nil
+}
+
+// Sync lets a slowSink be wrapped in a SyncWriter.
+func (s *slowSink) Sync() error { return nil }
+
+// TestAtomicWrites verifies messages from several loggers are not interleaved
+// in a shared sink, even when it is reached through a wrapper.
+func TestAtomicWrites(t *testing.T) {
+	s := new(slowSink)
+	loggers := []Logger{
+		NewLevel(LevelInfo, s, nil),
+		NewLevel(LevelWarning, NewSyncWriter(s), nil),
+		NewLevel(LevelError, Threshold(LevelError, s), nil),
+	}
+
+	var wg sync.WaitGroup
+	for _, l := range loggers {
+		for i := 0; i < 5; i++ {
+			wg.Add(1)
+			go func(l Logger) {
+				defer wg.Done()
+				for j := 0; j < 10; j++ {
+					l.Print("message")
+				}
+			}(l)
+		}
+	}
+	wg.Wait()
+
+	if n := s.overlaps.Load(); n != 0 {
+		t.Errorf("got %d overlapping writes want 0", n)
+	}
+	line := regexp.MustCompile(`^[IWE]\d{4} [0-9:.]{15} \S+ message$`)
+	for _, l := range regexp.MustCompile("\n").Split(string(s.data), -1) {
+		if l != "" && !line.MatchString(l) {
+			t.Errorf("got mixed up line %q", l)
+		}
+	}
+}
+
+// TestShortLivedSinks verifies the sinks of loggers that are dropped are
+// forgotten, and can be collected.
+func TestShortLivedSinks(t *testing.T) {
+	before := len(sinkStatesSnapshot())
+	const n = 100
+	var collected atomic.Int64
+	func() {
+		for i := 0; i < n; i++ {
+			b := new(bytes.Buffer)
+			runtime.SetFinalizer(b, func(*bytes.Buffer) { collected.Add(1) })
+			l := New("X", b, nil)
+			l.Print("message")
+			l.Clone().Print("cloned")
+		}
+	}()
+
+	for i := 0; i < 100 && collected.Load() < n; i++ {
+		runtime.GC()
+		time.Sleep(time.Millisecond)
+	}
+	if got := collected.Load(); got != n {
+		t.Errorf("got %d of %d sinks collected", got, n)
+	}
+	if got := len(sinkStatesSnapshot()); got > before {
+		t.Errorf("got %d sink states want at most %d", got, before)
+	}
+
+	// Replaced sinks are forgotten straight away.
+	b := new(bytes.Buffer)
+	l := New("X", b, nil)
+	if sinkStateFor(b) == nil {
+		t.Fatalf("got no state for an attached sink")
+	}
+	l.LogTo()
+	if sinkStateFor(b) != nil {
+		t.Errorf("got state for a sink after it was replaced")
+	}
+}
+
+// sinkStatesSnapshot returns a copy of sinkStates.
+func sinkStatesSnapshot() map[io.Writer]*sinkState {
+	sinkStatesLock.Lock()
+	defer sinkStatesLock.Unlock()
+	return maps.Clone(sinkStates)
+}
+
+type failingSink struct{}
+
+func (failingSink) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
+
+// TestFailingSink verifies a failing sink does not stop a message going to the
+// sinks after it.
+func TestFailingSink(t *testing.T) {
+	resetSinkErrors(t)
+	OnSinkError.Fallback = newSink()
+
+	s := newSink()
+	l := NewLevel(LevelInfo, nil, s.trigger)
+	l.LogTo(failingSink{}, s)
+
+	n, err := l.Print("message")
+	if err == nil || err.Error() != "disk full" || n != 0 {
+		t.Errorf("got %d, %v want 0, disk full", n, err)
+	}
+	if s.String() == "" {
+		t.Errorf("got nothing written to the sink after the failing one")
+	}
+	if s.triggers != 1 {
+		t.Errorf("got %d triggers want 1", s.triggers)
+	}
+}
+
+func TestSequenceNumbers(t *testing.T) {
+	defer func(b bool) { SequenceNumbers = b }(SequenceNumbers)
+	SequenceNumbers = true
+
+	s := newSink()
+	info := NewLevel(LevelInfo, s, nil)
+	warning := NewLevel(LevelWarning, s, nil)
+	info.Print("one")
+	warning.Print("two")
+	info.Print("three")
+
+	re := regexp.MustCompile(`(?m)^[IW]\d{4} [0-9:.]{15} #(\d+) \S+ (one|two|three)$`)
+	matches := re.FindAllStringSubmatch(s.String(), -1)
+	if len(matches) != 3 {
+		t.Fatalf("got %d matching lines want 3:\n%s", len(matches), s)
+	}
+	first, _ := strconv.ParseUint(matches[0][1], 10, 64)
+	for i, m := range matches {
+		if got, _ := strconv.ParseUint(m[1], 10, 64); got != first+uint64(i) {
+			t.Errorf("got sequence number %d want %d for %q", got, first+uint64(i), m[2])
+		}
+	}
+}
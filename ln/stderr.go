@@ -0,0 +1,49 @@
+package ln
+
+import (
+	"errors"
+	"os"
+)
+
+// CaptureStderr redirects file descriptor 2 into `l`, so output that bypasses
+// the loggers, like runtime errors, cgo libraries, and `println`, ends up in the
+// same sinks.
+//
+// The output is split into lines like a LineWriter, and each line is logged
+// labeled with `label`, like "stderr". Lines that already have an ln header,
+// like those from a child process that uses ln, are passed through as they are,
+// at their own level.
+//
+//	ln.LogAllTo(logFile)
+//	stop, err := ln.CaptureStderr(ln.Error, "stderr")
+//
+// Fails if any package logger writes to os.Stderr, which would feed its own
+// output back in forever. Point them somewhere else first.
+//
+// Output written just before the program dies may be lost, as the program can
+// die before reading it. Terminate, and so the Fatal logger, waits a moment for
+// it, but an unrecovered panic's stack trace can still go missing; for those,
+// see runtime/debug.SetCrashOutput.
+//
+// Call the returned function to put fd 2 back, and log any partial last line.
+//
+// Only supported on Linux. Elsewhere, returns an error.
+func CaptureStderr(l Logger, label string) (stop func() error, err error) {
+	if writesToStderr() {
+		return nil, errors.New("ln: cannot capture stderr while a logger writes to os.Stderr")
+	}
+	w := NewLineWriter(l, label, 0)
+	w.keepHeaders = true
+	return captureStderr(w)
+}
+
+// writesToStderr returns true if os.Stderr is a sink of any of the package
+// loggers, directly or through wrappers.
+func writesToStderr() bool {
+	for _, w := range sinks() {
+		if f, ok := w.(*os.File); ok && f == os.Stderr {
+			return true
+		}
+	}
+	return false
+}
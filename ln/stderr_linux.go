@@ -0,0 +1,156 @@
+package ln
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// stderrCapture is the state of a CaptureStderr call.
+type stderrCapture struct {
+	r     *os.File // Read end of the pipe on fd 2.
+	w     *LineWriter
+	saved int // The original fd 2.
+	done  chan struct{}
+
+	lock sync.Mutex // Held while writing what was read to w.
+}
+
+var (
+	captureLock sync.Mutex
+	capture     *stderrCapture // The current capture, or nil.
+	captureHook sync.Once      // Registers drainStderr with OnFatal.
+)
+
+// captureStderr puts the write end of a pipe on fd 2, and copies what is read
+// from it to `w`.
+func captureStderr(w *LineWriter) (stop func() error, err error) {
+	captureLock.Lock()
+	defer captureLock.Unlock()
+	if capture != nil {
+		return nil, errors.New("ln: stderr is already being captured")
+	}
+
+	r, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer pw.Close() // Only needed on fd 2.
+
+	syscall.ForkLock.RLock()
+	saved, err := syscall.Dup(2)
+	if err == nil {
+		syscall.CloseOnExec(saved)
+	}
+	syscall.ForkLock.RUnlock()
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+	if err := dupTo(pw, 2); err != nil {
+		syscall.Close(saved)
+		r.Close()
+		return nil, err
+	}
+
+	c := &stderrCapture{r: r, w: w, saved: saved, done: make(chan struct{})}
+	go c.copy()
+	capture = c
+	captureHook.Do(func() { OnFatal(drainStderr) })
+
+	var once sync.Once
+	return func() error {
+		var err error
+		once.Do(func() { err = c.stop() })
+		return err
+	}, nil
+}
+
+// dupTo duplicates `f` onto file descriptor `fd`, replacing what was there.
+func dupTo(f *os.File, fd int) error {
+	sc, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var dupErr error
+	if err := sc.Control(func(from uintptr) { dupErr = syscall.Dup3(int(from), fd, 0) }); err != nil {
+		return err
+	}
+	return dupErr
+}
+
+// copy copies from the pipe to the LineWriter until the write end is closed.
+func (c *stderrCapture) copy() {
+	defer close(c.done)
+	buf := make([]byte, 32<<10)
+	for {
+		n, err := c.r.Read(buf)
+		if n > 0 {
+			c.lock.Lock()
+			c.w.Write(buf[:n])
+			c.lock.Unlock()
+		}
+		if err != nil {
+			if err != io.EOF {
+				c.w.l.Printf("failed to read captured stderr: %v", err)
+			}
+			return
+		}
+	}
+}
+
+// stop puts the original fd 2 back, which closes the pipe, and waits for what
+// was left in it to be logged.
+//
+// Waits for good if a child process still has the pipe open.
+func (c *stderrCapture) stop() error {
+	captureLock.Lock()
+	defer captureLock.Unlock()
+	capture = nil
+
+	err := syscall.Dup3(c.saved, 2, 0)
+	syscall.Close(c.saved)
+	<-c.done
+	c.r.Close()
+	return errors.Join(err, c.w.Close())
+}
+
+// drainStderr waits up to a second for what has been written to the captured
+// fd 2 to be logged, so it is not lost when the program dies.
+func drainStderr() {
+	captureLock.Lock()
+	c := capture
+	captureLock.Unlock()
+	if c == nil {
+		return
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for c.pending() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.w.Flush()
+}
+
+// pending returns the number of bytes waiting to be read from the pipe, or 0
+// if it cannot tell.
+func (c *stderrCapture) pending() int {
+	sc, err := c.r.SyscallConn()
+	if err != nil {
+		return 0
+	}
+	var n int32
+	sc.Control(func(fd uintptr) {
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCINQ, uintptr(unsafe.Pointer(&n)))
+		if errno != 0 {
+			n = 0
+		}
+	})
+	return int(n)
+}
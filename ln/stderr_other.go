@@ -0,0 +1,13 @@
+//go:build !linux
+
+package ln
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// captureStderr is not supported here.
+func captureStderr(w *LineWriter) (stop func() error, err error) {
+	return nil, fmt.Errorf("ln: capturing stderr is not supported on %s", runtime.GOOS)
+}
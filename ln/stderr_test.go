@@ -0,0 +1,65 @@
+//go:build linux
+
+package ln
+
+import (
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestCaptureStderr(t *testing.T) {
+	snap := Snapshot()
+	defer snap.Restore()
+	s := newSink()
+	LogAllTo(s)
+
+	stop, err := CaptureStderr(Warning, "stderr")
+	if err != nil {
+		t.Fatalf("CaptureStderr: %v", err)
+	}
+	if _, err := CaptureStderr(Warning, "stderr"); err == nil {
+		t.Errorf("got nil error want one from a second CaptureStderr")
+	}
+	println("from println")
+	syscall.Write(2, []byte("E1018 10:15:00.000000 child(child.go:1) has a header\nno newline"))
+	if err := stop(); err != nil {
+		t.Errorf("stop: %v", err)
+	}
+	if err := stop(); err != nil {
+		t.Errorf("second stop: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(s.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines want 3:\n%s", len(lines), s)
+	}
+	for i, want := range []string{" stderr from println", "E1018 10:15:00.000000 child(child.go:1) has a header", " stderr no newline"} {
+		if i != 1 && (!strings.HasPrefix(lines[i], "W") || !strings.HasSuffix(lines[i], want)) {
+			t.Errorf("got %q want a Warning ending %q", lines[i], want)
+		}
+		if i == 1 && lines[i] != want {
+			t.Errorf("got %q want %q passed through", lines[i], want)
+		}
+	}
+
+	// fd 2 is back.
+	if _, err := os.Stderr.Write(nil); err != nil {
+		t.Errorf("got %v writing to restored stderr", err)
+	}
+}
+
+// TestCaptureStderrLoop verifies capturing fails while a logger writes to
+// os.Stderr.
+func TestCaptureStderrLoop(t *testing.T) {
+	snap := Snapshot()
+	defer snap.Restore()
+	LogAllTo(newSink())
+	Error.LogTo(Threshold(LevelError, os.Stderr))
+
+	if stop, err := CaptureStderr(Warning, "stderr"); err == nil {
+		stop()
+		t.Errorf("got nil error want one for a logger writing to os.Stderr")
+	}
+}
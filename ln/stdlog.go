@@ -0,0 +1,134 @@
+package ln
+
+import (
+	"bytes"
+	"log"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// CaptureStdLog redirects the output of the standard library's default logger
+// (used by log.Print, log.Printf, and so on, and by log/slog's default handler)
+// into the ln logger for `level`.
+//
+// Messages are attributed to the file and line the standard logger reports, so
+// wrappers that call log.Output with a larger calldepth are skipped, as they
+// intend. For that, its flags are set to Llongfile while captured (keeping
+// Lmsgprefix). The date, time, and file name it writes are removed, as ln adds
+// its own header.
+//
+// Returns a function that restores the previous output and flags.
+//
+// Note that log.Fatal and log.Panic still exit and panic as usual, whatever the
+// level.
+func CaptureStdLog(level Level) (restore func()) {
+	w, flags := log.Writer(), log.Flags()
+	log.SetOutput(stdLogWriter{level})
+	log.SetFlags(flags&log.Lmsgprefix | log.Llongfile)
+	return func() {
+		log.SetOutput(w)
+		log.SetFlags(flags)
+	}
+}
+
+// stdLogWriter receives messages from the standard library's default logger.
+type stdLogWriter struct {
+	level Level
+}
+
+func (w stdLogWriter) Write(p []byte) (int, error) {
+	lg := w.level.Logger().getLogger()
+	if lg == nil {
+		return len(p), nil
+	}
+	flags := log.Flags()
+	msg, file, line := splitStdLogHeader(string(bytes.TrimSuffix(p, []byte("\n"))), flags, log.Prefix())
+	_, err := lg.outputAt(stdLogCaller(file, line, flags&log.Llongfile != 0), msg)
+	return len(p), err
+}
+
+// stdLogCaller returns the callsite at `file` and `line` on the stack, where
+// `file` is the full path if `long`, or else just the name. If there is none
+// (like when the standard logger's flags were changed to leave out the file),
+// returns the first caller outside of the log packages, or nil if there is
+// none.
+//
+// The result is not cached, as the log functions may be inlined into their
+// callers, so the program counter does not identify the caller by itself.
+func stdLogCaller(file string, line int, long bool) *callsite {
+	var pcs [64]uintptr
+	n := runtime.Callers(3, pcs[:]) // Skip Callers, stdLogCaller, and Write.
+	frames := runtime.CallersFrames(pcs[:n])
+	var first *callsite
+	for {
+		frame, more := frames.Next()
+		if file != "" && frame.Line == line && (frame.File == file || !long && path.Base(frame.File) == file) {
+			return newCallsite(frame)
+		}
+		cs := newCallsite(frame)
+		if first == nil && cs != nil && cs.longPkg != "log" && cs.longPkg != "log/slog" && !strings.HasPrefix(cs.longPkg, "log/slog/") {
+			first = cs
+		}
+		if !more {
+			return first
+		}
+	}
+}
+
+// splitStdLogHeader removes the date, time, and file name added by the
+// standard library's logger with the given flags from `s`, keeping the prefix.
+//
+// Returns the file name and line, if the flags include them.
+func splitStdLogHeader(s string, flags int, prefix string) (msg, file string, line int) {
+	if flags&(log.Ldate|log.Ltime|log.Lmicroseconds|log.Lshortfile|log.Llongfile) == 0 {
+		return s, "", 0
+	}
+
+	msgPrefix := flags&log.Lmsgprefix != 0
+	if !msgPrefix {
+		if !strings.HasPrefix(s, prefix) {
+			return s, "", 0
+		}
+		s = s[len(prefix):]
+	}
+
+	// Each part has a fixed width, like "2009/01/23 01:23:23.123123 ".
+	n := 0
+	if flags&log.Ldate != 0 {
+		n += len("2009/01/23 ")
+	}
+	if flags&(log.Ltime|log.Lmicroseconds) != 0 {
+		n += len("01:23:23 ")
+		if flags&log.Lmicroseconds != 0 {
+			n += len(".123123")
+		}
+	}
+	if n > len(s) {
+		n = len(s)
+	}
+	s = s[n:]
+
+	if flags&(log.Lshortfile|log.Llongfile) != 0 {
+		file, line, s = cutFileLine(s)
+	}
+
+	if !msgPrefix {
+		s = prefix + s
+	}
+	return s, file, line
+}
+
+// cutFileLine splits "file:line: rest" into its parts. If `s` does not start
+// like that, returns it as the rest.
+func cutFileLine(s string) (file string, line int, rest string) {
+	// The file name may hold colons itself, like "C:/src/a.go:12: msg".
+	head, rest, ok := strings.Cut(s, ": ")
+	if colon := strings.LastIndexByte(head, ':'); ok && colon != -1 {
+		if n, err := strconv.Atoi(head[colon+1:]); err == nil {
+			return head[:colon], n, rest
+		}
+	}
+	return "", 0, s
+}
@@ -0,0 +1,76 @@
+package ln
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"regexp"
+	"testing"
+)
+
+// logWrapper logs like a helper that reports its caller's line, not its own.
+func logWrapper(msg string) {
+	log.Output(2, msg)
+}
+
+func TestCaptureStdLog(t *testing.T) {
+	defer Snapshot().Restore()
+	s := newSink()
+	Warning.LogTo(s)
+
+	defer log.SetOutput(log.Writer())
+	defer log.SetFlags(log.Flags())
+	var before bytes.Buffer
+	log.SetOutput(&before)
+	log.SetFlags(log.LstdFlags)
+
+	restore := CaptureStdLog(LevelWarning)
+	log.Printf("hello %d", 1)
+	logWrapper("wrapped")
+	log.SetFlags(log.LstdFlags | log.Lmicroseconds | log.Lshortfile)
+	log.SetPrefix("pre: ")
+	log.Print("flags")
+	log.SetFlags(log.LstdFlags | log.Lmsgprefix)
+	log.Print("msgprefix")
+	log.SetPrefix("")
+	slog.Info("structured", "k", "v")
+	log.SetFlags(0)
+	logWrapper("no file")
+	restore()
+	log.SetFlags(0)
+	log.Print("after")
+
+	want := regexp.MustCompile(`^` +
+		`W\d{4} [0-9:.]{15} TestCaptureStdLog\(stdlog_test.go:\d+\) hello 1\n` +
+		`W\d{4} [0-9:.]{15} TestCaptureStdLog\(stdlog_test.go:\d+\) wrapped\n` +
+		`W\d{4} [0-9:.]{15} TestCaptureStdLog\(stdlog_test.go:\d+\) pre: flags\n` +
+		`W\d{4} [0-9:.]{15} TestCaptureStdLog\(stdlog_test.go:\d+\) pre: msgprefix\n` +
+		`W\d{4} [0-9:.]{15} TestCaptureStdLog\(stdlog_test.go:\d+\) INFO structured k=v\n` +
+		// Without the file name, falls back to the first caller outside of log.
+		`W\d{4} [0-9:.]{15} logWrapper\(stdlog_test.go:\d+\) no file\n` +
+		`$`)
+	if got := s.String(); !want.MatchString(got) {
+		t.Errorf("got\n%s\nwant match for\n%s", got, want)
+	}
+	if got := before.String(); got != "after\n" {
+		t.Errorf("got %q want %q after restore", got, "after\n")
+	}
+}
+
+func TestCutFileLine(t *testing.T) {
+	for _, tc := range []struct {
+		s, file string
+		line    int
+		rest    string
+	}{
+		{"/src/a.go:12: msg", "/src/a.go", 12, "msg"},
+		{"C:/src/a.go:3: msg: more", "C:/src/a.go", 3, "msg: more"},
+		{"???:0: msg", "???", 0, "msg"},
+		{"no file: msg", "", 0, "no file: msg"},
+	} {
+		file, line, rest := cutFileLine(tc.s)
+		if file != tc.file || line != tc.line || rest != tc.rest {
+			t.Errorf("got %q, %d, %q want %q, %d, %q for %q", file, line, rest, tc.file, tc.line, tc.rest, tc.s)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package ln
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimedIndent is repeated once for each enclosing timed region on the same
+// goroutine, before the messages logged by Timed and TimedAbove. Set it to ""
+// to turn off indentation, which also saves looking up the goroutine.
+var TimedIndent = "  "
+
+// Timed logs the start of a region of code, and returns a function that logs
+// the end of it along with how long it took. Both messages are attributed to
+// the caller of Timed.
+//
+//	defer ln.V(2).Timed("load shard %d", id)()
+//
+// logs something like:
+//
+//	I1018 10:15:00.000000 load(shard.go:12) load shard 3 started
+//	I1018 10:15:01.234567 load(shard.go:12) load shard 3 finished in 1.234567s
+//
+// Regions nested on the same goroutine are indented by TimedIndent.
+//
+// Does nothing for the nil logger, so disabled regions cost next to nothing.
+func (l Logger) Timed(format string, a ...any) func() {
+	lg := l.getLogger()
+	if lg == nil {
+		return func() {}
+	}
+	return lg.timed(callsiteAt(1), -1, fmt.Sprintf(format, a...))
+}
+
+// TimedAbove is like Timed, but only logs the end of the region, and only if it
+// took at least `threshold`. Useful for finding slow paths without logging the
+// fast ones:
+//
+//	defer ln.Warning.TimedAbove(time.Second, "handle %s", req.URL)()
+func (l Logger) TimedAbove(threshold time.Duration, format string, a ...any) func() {
+	lg := l.getLogger()
+	if lg == nil {
+		return func() {}
+	}
+	return lg.timed(callsiteAt(1), threshold, fmt.Sprintf(format, a...))
+}
+
+// timed implements Timed and TimedAbove. A negative `threshold` logs the start
+// and the end of the region.
+func (l *logger) timed(cs *callsite, threshold time.Duration, msg string) func() {
+	if l.level == LevelDebug && debugDisabled(cs) {
+		return func() {}
+	}
+
+	indent := ""
+	var gid uint64
+	if TimedIndent != "" {
+		if gid = goroutineID(); gid != 0 {
+			indent = strings.Repeat(TimedIndent, enterRegion(gid))
+		}
+	}
+	if threshold < 0 {
+		l.outputAt(cs, indent+msg+" started")
+	}
+
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		if gid != 0 {
+			leaveRegion(gid)
+		}
+		if elapsed >= threshold {
+			l.outputAt(cs, indent+msg+" finished in "+elapsed.Round(time.Microsecond).String())
+		}
+	}
+}
+
+var (
+	regionLock sync.Mutex
+	regions    = make(map[uint64]int) // Goroutine ID to the number of open regions.
+)
+
+// enterRegion records the start of a timed region on goroutine `gid`, and
+// returns the number of regions it was already in.
+func enterRegion(gid uint64) int {
+	regionLock.Lock()
+	defer regionLock.Unlock()
+	depth := regions[gid]
+	regions[gid] = depth + 1
+	return depth
+}
+
+// leaveRegion records the end of a timed region on goroutine `gid`.
+func leaveRegion(gid uint64) {
+	regionLock.Lock()
+	defer regionLock.Unlock()
+	if depth := regions[gid] - 1; depth > 0 {
+		regions[gid] = depth
+	} else {
+		delete(regions, gid)
+	}
+}
+
+// goroutineID returns the ID of the current goroutine, or 0 if it cannot be
+// determined.
+//
+// Go does not expose goroutine IDs, on purpose, so this parses them out of the
+// first line of the stack trace, like "goroutine 123 [running]:".
+func goroutineID() uint64 {
+	var buf [64]byte
+	b := buf[:runtime.Stack(buf[:], false)]
+	b = bytes.TrimPrefix(b, []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		b = b[:i]
+	}
+	id, err := strconv.ParseUint(string(b), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
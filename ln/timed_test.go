@@ -0,0 +1,105 @@
+package ln
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// timedMessages returns the messages logged to `s`, after the header, which
+// must name TestTimed as the caller.
+func timedMessages(t *testing.T, s *sink) []string {
+	t.Helper()
+	header := regexp.MustCompile(`^I\d{4} [0-9:.]{15} TestTimed\w*\(timed_test.go:\d+\) `)
+	var msgs []string
+	for _, line := range strings.Split(strings.TrimSuffix(s.String(), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		loc := header.FindStringIndex(line)
+		if loc == nil {
+			t.Errorf("got %q want a header naming the Timed caller", line)
+			continue
+		}
+		msgs = append(msgs, line[loc[1]:])
+	}
+	return msgs
+}
+
+// durations matches the elapsed time at the end of a message.
+var durations = regexp.MustCompile(`in [0-9.]+[µmn]?s$`)
+
+func TestTimed(t *testing.T) {
+	s := newSink()
+	l := NewLevel(LevelInfo, s, nil)
+
+	outer := l.Timed("outer %d", 1)
+	inner := l.Timed("inner")
+	NilLogger().Timed("disabled")() // Does not count towards nesting.
+	inner()
+	outer()
+	l.Timed("after")()
+
+	want := []string{
+		"outer 1 started",
+		"  inner started",
+		"  inner finished in X",
+		"outer 1 finished in X",
+		"after started",
+		"after finished in X",
+	}
+	got := timedMessages(t, s)
+	for i := range got {
+		got[i] = durations.ReplaceAllString(got[i], "in X")
+	}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q want %q", got, want)
+	}
+}
+
+func TestTimedAbove(t *testing.T) {
+	s := newSink()
+	l := NewLevel(LevelInfo, s, nil)
+
+	l.TimedAbove(time.Hour, "fast")()
+	slow := l.TimedAbove(time.Millisecond, "slow")
+	time.Sleep(2 * time.Millisecond)
+	slow()
+
+	got := timedMessages(t, s)
+	if len(got) != 1 || !strings.HasPrefix(got[0], "slow finished in ") {
+		t.Errorf("got %q want just the end of the slow region", got)
+	}
+}
+
+// TestTimedGoroutines verifies nesting is tracked per goroutine.
+func TestTimedGoroutines(t *testing.T) {
+	s := newSink()
+	l := NewLevel(LevelInfo, s, nil)
+	defer l.TimedAbove(time.Hour, "outer")()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.TimedAbove(0, "region")()
+		}()
+	}
+	wg.Wait()
+
+	// Logged from the goroutines, so the caller is the function literal.
+	for _, line := range strings.Split(strings.TrimSuffix(s.String(), "\n"), "\n") {
+		if !strings.Contains(line, ") region finished in ") {
+			t.Errorf("got %q want an unindented region", line)
+		}
+	}
+
+	regionLock.Lock()
+	defer regionLock.Unlock()
+	if len(regions) != 1 {
+		t.Errorf("got %d goroutines with open regions want 1 (this one)", len(regions))
+	}
+}
@@ -0,0 +1,128 @@
+package ln
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// vmoduleRule sets the verbosity for source files matching a pattern.
+type vmoduleRule struct {
+	pattern string // A path.Match pattern.
+	full    bool   // Match against the full path instead of the base name.
+	v       int
+}
+
+// vmoduleRules is an immutable set of rules parsed by SetVModule.
+type vmoduleRules struct {
+	spec  string
+	rules []vmoduleRule
+}
+
+// vmoduleMatch caches the result of matching a callsite against a set of rules.
+type vmoduleMatch struct {
+	rules *vmoduleRules // The rules that were matched against.
+	v     int
+	ok    bool
+}
+
+// vmodule holds the current *vmoduleRules for the default scope, or nil.
+var vmodule atomic.Pointer[vmoduleRules]
+
+// SetVModule sets per-file verbosity overrides, like glog's `-vmodule` flag.
+//
+// The spec is a comma-separated list of `pattern=verbosity`. Patterns use
+// path.Match syntax, and are matched against the name of the source file
+// without its ".go" suffix. Patterns containing a "/" are matched against the
+// trailing elements of the full path instead, so "ln/env" matches
+// ".../ln/env.go". The first matching pattern wins.
+//
+// For example, "server=2,handler_*=3" sets V(2) for server.go, and V(3) for
+// every file beginning with handler_.
+//
+// File overrides take precedence over PackageVerbosity and Verbosity.
+//
+// Replaces any previous overrides. An empty spec removes them.
+func SetVModule(spec string) error {
+	return defaultScope.SetVModule(spec)
+}
+
+// parseVModule parses a spec for SetVModule, returning nil for an empty spec.
+func parseVModule(spec string) (*vmoduleRules, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	rs := &vmoduleRules{spec: spec}
+	for _, part := range strings.Split(spec, ",") {
+		pattern, v, ok := strings.Cut(part, "=")
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("'%s' in vmodule '%s' not in 'pattern=verbosity' format", part, spec)
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("'%s' in vmodule '%s': bad pattern: %w", part, spec, err)
+		}
+		verb, err := strconv.ParseInt(v, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' in vmodule '%s': bad verbosity: %w", part, spec, err)
+		}
+		rs.rules = append(rs.rules, vmoduleRule{
+			pattern: strings.TrimSuffix(pattern, ".go"),
+			full:    strings.Contains(pattern, "/"),
+			v:       int(verb),
+		})
+	}
+	return rs, nil
+}
+
+// VModule returns the spec most recently passed to SetVModule.
+func VModule() string {
+	return defaultScope.VModule()
+}
+
+// moduleVerbosity returns the file-specific verbosity for the callsite from
+// `rs`, or false if there is none.
+//
+// The result is cached in the callsite until the rules change.
+func (cs *callsite) moduleVerbosity(rs *vmoduleRules) (v int, ok bool) {
+	if rs == nil {
+		return
+	}
+	if m := cs.vmodule.Load(); m != nil && m.rules == rs {
+		return m.v, m.ok
+	}
+
+	m := &vmoduleMatch{rules: rs}
+	file := strings.TrimSuffix(cs.fullFile, ".go")
+	base := path.Base(file)
+	for _, r := range rs.rules {
+		if r.full && matchTrailing(r.pattern, file) || !r.full && matchName(r.pattern, base) {
+			m.v, m.ok = r.v, true
+			break
+		}
+	}
+	cs.vmodule.Store(m)
+	return m.v, m.ok
+}
+
+func matchName(pattern, name string) bool {
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
+
+// matchTrailing reports whether `pattern` matches `file`, or any of its
+// trailing path elements.
+func matchTrailing(pattern, file string) bool {
+	for {
+		if matchName(pattern, file) {
+			return true
+		}
+		slash := strings.Index(file, "/")
+		if slash == -1 {
+			return false
+		}
+		file = file[slash+1:]
+	}
+}